@@ -0,0 +1,157 @@
+package formam
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Allow returns a copy of the decoder that only decodes the keys with a path
+// that matches one of the patterns, or is under one of them. The patterns are
+// paths with the names in the form keys, and a * matches any index, map key
+// or field name; e.g. "Name", "Address" or "Phones[*].Number".
+//
+// A struct field matches its name and the name in its tag, however it is
+// written in the key, and the fields of an embedded struct match with or
+// without the name of the embedded struct; e.g. "is_admin" and
+// "Base.IsAdmin" match the field IsAdmin `formam:"is_admin"` of an embedded
+// Base.
+//
+// The other keys are skipped, or rejected with ErrCodeNotAllowed if the
+// RejectDisallowed option is set. It panics if a pattern can't be parsed by
+// the PathParser.
+func (dec Decoder) Allow(patterns ...string) *Decoder {
+	dec.allow = dec.appendPatterns(dec.allow, patterns)
+	return &dec
+}
+
+// Deny returns a copy of the decoder that doesn't decode the keys with a path
+// that matches one of the patterns, or is under one of them; e.g. "IsAdmin"
+// or "Users[*].Role". See Allow for the syntax of the patterns.
+func (dec Decoder) Deny(patterns ...string) *Decoder {
+	dec.deny = dec.appendPatterns(dec.deny, patterns)
+	return &dec
+}
+
+// Groups returns a copy of the decoder that decodes the struct fields of the
+// groups, set by the "groups" tag option; e.g. with dec.Groups("admin") and:
+//
+//	Role string `formam:"role,groups=admin|owner"`
+//
+// The fields without groups are decoded with any group, and the ones with
+// groups are not decoded without Groups. The groups of an embedded struct
+// apply to its fields. See Allow for the disallowed keys.
+func (dec Decoder) Groups(groups ...string) *Decoder {
+	dec.groups = append(dec.groups[:len(dec.groups):len(dec.groups)], groups...)
+	return &dec
+}
+
+// appendPatterns parses the patterns and appends them to a copy of dst.
+func (dec *Decoder) appendPatterns(dst [][]PathSegment, patterns []string) [][]PathSegment {
+	dst = dst[:len(dst):len(dst)]
+	for _, p := range patterns {
+		segs, err := dec.opts.PathParser.ParsePath(p)
+		if err != nil {
+			panic(fmt.Sprintf("formam: invalid path pattern %q: %s", p, err))
+		}
+		dst = append(dst, segs)
+	}
+	return dst
+}
+
+// pathName is a segment of the path matched by Allow and Deny: a map key, an
+// index, or a struct field with its name and the name in its tag.
+type pathName struct {
+	name, tag string
+	embedded  bool              // an embedded struct, that can be omitted in the patterns
+	fieldTag  reflect.StructTag // tag of a struct field, for Groups
+}
+
+// fieldName returns the pathName of a struct field.
+func (dec *Decoder) fieldName(field reflect.StructField) pathName {
+	return pathName{field.Name, getTagName(field.Tag, dec.opts.TagName), field.Anonymous, field.Tag}
+}
+
+// is reports if the name of a pattern matches the segment.
+func (n pathName) is(name string) bool {
+	return name == "*" || name == n.name || (n.tag != "" && name == n.tag)
+}
+
+// allowed checks if the current path, followed by names, is allowed by Allow
+// and Deny. Unless last is true, a path that can lead to an allowed one is
+// allowed.
+func (dec *Decoder) allowed(last bool, names ...pathName) error {
+	if dec.allow == nil && dec.deny == nil {
+		return nil
+	}
+	path := append(dec.names[:len(dec.names):len(dec.names)], names...)
+	for _, p := range dec.deny {
+		if ok, _ := matchPathPrefix(p, path); ok {
+			return dec.notAllowed()
+		}
+	}
+	if dec.allow == nil {
+		return nil
+	}
+	for _, p := range dec.allow {
+		if ok, prefix := matchPathPrefix(p, path); ok || (prefix && !last) {
+			return nil
+		}
+	}
+	return dec.notAllowed()
+}
+
+// inGroups reports if the struct field with the tag is in one of the groups
+// of Groups, or has none.
+func (dec *Decoder) inGroups(tag reflect.StructTag) bool {
+	if tag == "" {
+		return true
+	}
+	groups, ok := tagOption(tag, dec.opts.TagName, "groups")
+	if !ok {
+		return true
+	}
+	for groups != "" {
+		var g string
+		if p := strings.IndexByte(groups, '|'); p != -1 {
+			g, groups = groups[:p], groups[p+1:]
+		} else {
+			g, groups = groups, ""
+		}
+		for _, group := range dec.groups {
+			if g == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// notAllowed returns the error for a path that is not allowed: errSkip, or an
+// error with ErrCodeNotAllowed if the RejectDisallowed option is set.
+func (dec *Decoder) notAllowed() error {
+	if !dec.opts.RejectDisallowed {
+		return errSkip
+	}
+	return dec.newError(ErrCodeNotAllowed, "field is not allowed")
+}
+
+// matchPathPrefix reports whether a prefix of path matches the pattern, where
+// * matches any name and the embedded structs can be omitted, and whether
+// path matches a prefix of the pattern.
+func matchPathPrefix(pattern []PathSegment, path []pathName) (match, prefix bool) {
+	if len(pattern) == 0 {
+		return true, len(path) == 0
+	}
+	if len(path) == 0 {
+		return false, true
+	}
+	if path[0].embedded {
+		match, prefix = matchPathPrefix(pattern, path[1:])
+	}
+	if path[0].is(pattern[0].Name) {
+		m, p := matchPathPrefix(pattern[1:], path[1:])
+		match, prefix = match || m, prefix || p
+	}
+	return match, prefix
+}
@@ -0,0 +1,191 @@
+package formam_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+type allowUser struct {
+	Name    string
+	IsAdmin bool
+	Role    string `formam:"role,groups=admin|owner"`
+	Notes   string `formam:"notes,groups=staff"`
+	Address struct {
+		City    string
+		Country string
+	}
+	Phones []struct {
+		Number   string
+		Verified bool
+	}
+	Meta map[string]string
+}
+
+func TestAllow(t *testing.T) {
+	vals := url.Values{
+		"Name":               {"Homer"},
+		"IsAdmin":            {"true"},
+		"role":               {"owner"},
+		"notes":              {"nuclear"},
+		"Address.City":       {"Springfield"},
+		"Address.Country":    {"USA"},
+		"Phones[0].Number":   {"555"},
+		"Phones[0].Verified": {"true"},
+		"Meta[a]":            {"1"},
+		"Meta[b]":            {"2"},
+	}
+
+	tests := []struct {
+		name string
+		dec  *formam.Decoder
+		want func(u *allowUser) bool
+	}{
+		{"allow", formam.NewDecoder(nil).Allow("Name", "Address", "Phones[*].Number", "Meta[a]"), func(u *allowUser) bool {
+			return u.Name == "Homer" && !u.IsAdmin && u.Role == "" && u.Address.City == "Springfield" && u.Address.Country == "USA" &&
+				len(u.Phones) == 1 && u.Phones[0].Number == "555" && !u.Phones[0].Verified && len(u.Meta) == 1 && u.Meta["a"] == "1"
+		}},
+		{"deny", formam.NewDecoder(nil).Deny("IsAdmin", "Phones[*].Verified", "Meta[*]"), func(u *allowUser) bool {
+			return u.Name == "Homer" && !u.IsAdmin && u.Role == "" && u.Address.Country == "USA" &&
+				u.Phones[0].Number == "555" && !u.Phones[0].Verified && u.Meta == nil
+		}},
+		{"allow and deny", formam.NewDecoder(nil).Allow("Address", "Name").Deny("Address.Country"), func(u *allowUser) bool {
+			return u.Name == "Homer" && u.Address.City == "Springfield" && u.Address.Country == "" && u.Phones == nil
+		}},
+		{"groups", formam.NewDecoder(nil).Groups("owner"), func(u *allowUser) bool {
+			return u.Name == "Homer" && u.IsAdmin && u.Role == "owner" && u.Notes == ""
+		}},
+		{"several groups", formam.NewDecoder(nil).Groups("staff").Groups("admin"), func(u *allowUser) bool {
+			return u.Role == "owner" && u.Notes == "nuclear"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var u allowUser
+			if err := tt.dec.Decode(vals, &u); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(&u) {
+				t.Errorf("%+v", u)
+			}
+		})
+	}
+
+	// a shared decoder is not changed by the copies
+	dec := formam.NewDecoder(nil)
+	dec.Allow("Name")
+	dec.Groups("admin")
+	var u allowUser
+	if err := dec.Decode(vals, &u); err != nil {
+		t.Fatal(err)
+	}
+	if !u.IsAdmin || u.Role != "" {
+		t.Errorf("%+v", u)
+	}
+}
+
+func TestRejectDisallowed(t *testing.T) {
+	dec := formam.NewDecoder(&formam.DecoderOptions{RejectDisallowed: true, IgnoreUnknownKeys: true})
+	for _, tt := range []struct {
+		dec  *formam.Decoder
+		key  string
+		path string
+	}{
+		{dec.Allow("Name"), "IsAdmin", "IsAdmin"},
+		{dec.Allow("Address.City"), "Address", "Address"},
+		{dec.Deny("Phones"), "Phones[0].Number", "Phones[0].Number"},
+		{dec, "role", "role"},
+	} {
+		t.Run(tt.key, func(t *testing.T) {
+			var u allowUser
+			err := tt.dec.Decode(url.Values{tt.key: {"x"}, "Name": {"Homer"}}, &u)
+			if e, ok := err.(*formam.Error); !ok || e.Code() != formam.ErrCodeNotAllowed || e.Path() != tt.path {
+				t.Errorf("wrong error: %v", err)
+			}
+		})
+	}
+}
+
+type AllowBase struct {
+	IsAdmin bool `formam:"is_admin"`
+}
+
+type AllowStaff struct {
+	Salary int
+}
+
+type allowEmbedded struct {
+	AllowBase
+	*AllowStaff `formam:",groups=staff"`
+	Name        string
+	Sub         struct {
+		Role string `formam:"role,groups=admin"`
+	}
+}
+
+func TestAllowAliases(t *testing.T) {
+	dec := formam.NewDecoder(&formam.DecoderOptions{RejectDisallowed: true, BracketFields: true})
+	for _, key := range []string{"IsAdmin", "is_admin", "AllowBase.IsAdmin", "AllowBase.is_admin", "AllowBase[is_admin]"} {
+		for _, tt := range []struct {
+			name string
+			dec  *formam.Decoder
+		}{
+			{"deny tag name", dec.Deny("is_admin")},
+			{"deny name", dec.Deny("IsAdmin")},
+			{"deny embedded", dec.Deny("AllowBase")},
+			{"deny embedded field", dec.Deny("AllowBase.is_admin")},
+			{"allow", dec.Allow("Name")},
+		} {
+			t.Run(tt.name+"/"+key, func(t *testing.T) {
+				var v allowEmbedded
+				err := tt.dec.Decode(url.Values{key: {"1"}}, &v)
+				if e, ok := err.(*formam.Error); !ok || e.Code() != formam.ErrCodeNotAllowed {
+					t.Errorf("wrong error: %v", err)
+				}
+				if v.IsAdmin {
+					t.Error("IsAdmin is set")
+				}
+			})
+		}
+	}
+
+	// the allowed aliases
+	for _, key := range []string{"IsAdmin", "AllowBase.is_admin"} {
+		var v allowEmbedded
+		if err := dec.Allow("is_admin").Decode(url.Values{key: {"1"}}, &v); err != nil || !v.IsAdmin {
+			t.Errorf("%s: %v, %+v", key, err, v)
+		}
+	}
+}
+
+func TestGroupsBrackets(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		dec  *formam.Decoder
+		key  string
+	}{
+		{"field", formam.NewDecoder(nil), "Sub.role"},
+		{"bracket fields", formam.NewDecoder(&formam.DecoderOptions{BracketFields: true}), "Sub[role]"},
+		{"bracket path parser", formam.NewDecoder(&formam.DecoderOptions{PathParser: formam.BracketPathParser{}}), "Sub[role]"},
+		{"embedded", formam.NewDecoder(nil), "Salary"},
+		{"embedded by name", formam.NewDecoder(nil), "AllowStaff.Salary"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var v allowEmbedded
+			if err := tt.dec.Decode(url.Values{tt.key: {"1"}}, &v); err != nil {
+				t.Fatal(err)
+			}
+			if v.Sub.Role != "" || v.AllowStaff != nil && v.Salary != 0 {
+				t.Errorf("%+v", v)
+			}
+
+			if err := tt.dec.Groups("admin", "staff").Decode(url.Values{tt.key: {"1"}}, &v); err != nil {
+				t.Fatal(err)
+			}
+			if v.Sub.Role != "1" && (v.AllowStaff == nil || v.Salary != 1) {
+				t.Errorf("%+v", v)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package formam
+
+import (
+	"reflect"
+)
+
+// CollectionMode is how the values are set in the slices and maps that
+// already have elements.
+type CollectionMode uint8
+
+// Collection modes.
+const (
+	// MergeCollections sets the elements with an index or key in the form,
+	// keeping the others. The values of a key without index, like tags or
+	// tags[], are set from the first element, and the elements after them
+	// are kept.
+	MergeCollections CollectionMode = iota
+
+	// ReplaceCollections empties the slices and maps with any key in the
+	// form before setting their elements, so the elements not in the form
+	// are removed.
+	ReplaceCollections
+
+	// AppendCollections adds the elements in the form after the ones of the
+	// slices, so the index 0 is the first element added. The maps are
+	// merged.
+	AppendCollections
+)
+
+// collection prepares the current slice or map for the Collections option
+// the first time it's traversed, and sets the offset of the indexes.
+func (dec *Decoder) collection() {
+	dec.offset = 0
+	if dec.opts.Collections == MergeCollections {
+		return
+	}
+	kind := dec.curr.Kind()
+	if kind != reflect.Slice && kind != reflect.Map {
+		return
+	}
+
+	path := FormatPath(dec.segs)
+	if n, ok := dec.collections[path]; ok {
+		dec.offset = n
+		return
+	}
+	if dec.collections == nil {
+		dec.collections = make(map[string]int)
+	}
+	n := 0
+	switch dec.opts.Collections {
+	case ReplaceCollections:
+		if kind == reflect.Map {
+			// the maps in interfaces are not addressable
+			for _, k := range dec.curr.MapKeys() {
+				dec.curr.SetMapIndex(k, reflect.Value{})
+			}
+		} else if dec.curr.CanSet() {
+			dec.curr.Set(reflect.Zero(dec.curr.Type()))
+		}
+	case AppendCollections:
+		if kind == reflect.Slice {
+			n = dec.curr.Len()
+		}
+	}
+	dec.collections[path] = n
+	dec.offset = n
+}
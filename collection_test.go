@@ -0,0 +1,105 @@
+package formam_test
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+type collectionItem struct {
+	Name string
+	Qty  int
+}
+
+type collectionForm struct {
+	Tags  []string
+	Items []collectionItem
+	Meta  map[string]string
+	Other []string
+}
+
+func TestCollections(t *testing.T) {
+	loaded := func() collectionForm {
+		return collectionForm{
+			Tags:  []string{"a", "b", "c"},
+			Items: []collectionItem{{"x", 1}, {"y", 2}},
+			Meta:  map[string]string{"k1": "v1", "k2": "v2"},
+			Other: []string{"kept"},
+		}
+	}
+	vals := url.Values{
+		"Tags":          {"d"},
+		"Items[1].Qty":  {"5"},
+		"Items[2].Name": {"z"},
+		"Meta[k2]":      {"new"},
+		"Meta[k3]":      {"v3"},
+	}
+
+	tests := []struct {
+		mode formam.CollectionMode
+		want collectionForm
+	}{
+		{formam.MergeCollections, collectionForm{
+			Tags:  []string{"d", "b", "c"},
+			Items: []collectionItem{{"x", 1}, {"y", 5}, {"z", 0}},
+			Meta:  map[string]string{"k1": "v1", "k2": "new", "k3": "v3"},
+			Other: []string{"kept"},
+		}},
+		{formam.ReplaceCollections, collectionForm{
+			Tags:  []string{"d"},
+			Items: []collectionItem{{}, {"", 5}, {"z", 0}},
+			Meta:  map[string]string{"k2": "new", "k3": "v3"},
+			Other: []string{"kept"},
+		}},
+		{formam.AppendCollections, collectionForm{
+			Tags:  []string{"a", "b", "c", "d"},
+			Items: []collectionItem{{"x", 1}, {"y", 2}, {}, {"", 5}, {"z", 0}},
+			Meta:  map[string]string{"k1": "v1", "k2": "new", "k3": "v3"},
+			Other: []string{"kept"},
+		}},
+	}
+	for _, tt := range tests {
+		f := loaded()
+		err := formam.NewDecoder(&formam.DecoderOptions{Collections: tt.mode}).Decode(vals, &f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(f, tt.want) {
+			t.Errorf("mode %d\nhave: %+v\nwant: %+v", tt.mode, f, tt.want)
+		}
+	}
+}
+
+func TestCollectionsRows(t *testing.T) {
+	vals := url.Values{
+		"Tags[]":        {"d", "e"},
+		"Items[][Name]": {"p", "q"},
+	}
+	f := collectionForm{
+		Tags:  []string{"a"},
+		Items: []collectionItem{{"x", 1}},
+	}
+	dec := formam.NewDecoder(&formam.DecoderOptions{Collections: formam.AppendCollections, BracketFields: true})
+	if err := dec.Decode(vals, &f); err != nil {
+		t.Fatal(err)
+	}
+	want := collectionForm{
+		Tags:  []string{"a", "d", "e"},
+		Items: []collectionItem{{"x", 1}, {"p", 0}, {"q", 0}},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("\nhave: %+v\nwant: %+v", f, want)
+	}
+
+	// a map in the root
+	m := map[string]string{"a": "1"}
+	err := formam.NewDecoder(&formam.DecoderOptions{Collections: formam.ReplaceCollections}).Decode(url.Values{"b": {"2"}}, &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, map[string]string{"b": "2"}) {
+		t.Errorf("%v", m)
+	}
+}
@@ -0,0 +1,102 @@
+package formam
+
+import (
+	"context"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// FieldContext is the context of the field decoded by a custom type
+// registered with RegisterCustomTypeContext. Its Context is the one passed
+// to DecodeContext, or context.Background() for Decode.
+type FieldContext struct {
+	context.Context
+
+	// Path of the value, e.g. "Events[0].Start".
+	Path string
+
+	// Struct field of the value, or of the slice or map that has the value.
+	// It's the zero value for the destination and its elements.
+	Field reflect.StructField
+
+	tagName string
+}
+
+// Options returns the options after the name in the tag of the field, such
+// as ["trim", "layout=02/01/2006"] in `formam:"start,trim,layout=02/01/2006"`.
+func (c FieldContext) Options() []string {
+	opts, ok := tagOptions(c.Field.Tag, c.tagName)
+	if !ok {
+		return nil
+	}
+	return strings.Split(opts, ",")
+}
+
+// Option returns the value of the option key in the tag of the field, such
+// as "02/01/2006" for the key "layout" in `formam:"start,layout=02/01/2006"`.
+func (c FieldContext) Option(key string) (string, bool) {
+	return tagOption(c.Field.Tag, c.tagName, key)
+}
+
+// DecodeCustomTypeContextFunc for decoding a custom type with the context of
+// the field.
+type DecodeCustomTypeContextFunc func(ctx FieldContext, vals []string) (interface{}, error)
+
+// RegisterCustomTypeContext registers a function for decoding custom types,
+// which gets the context of the field: its path, struct field, tag options
+// and the context passed to DecodeContext. For example, to decode a value
+// with the locale of the request:
+//
+//	dec.RegisterCustomTypeContext(func(ctx formam.FieldContext, vals []string) (interface{}, error) {
+//		return parseMoney(vals[0], ctx.Value(localeKey).(string))
+//	}, []interface{}{Money{}})
+//
+// It has preference over the functions registered with RegisterCustomType
+// for all the fields, but not over the ones for specific fields.
+func (dec *Decoder) RegisterCustomTypeContext(fn DecodeCustomTypeContextFunc, types []interface{}) *Decoder {
+	if dec.customTypes == nil {
+		dec.customTypes = make(map[reflect.Type]*decodeCustomType, 100)
+	}
+	for i := range types {
+		typ := reflect.TypeOf(types[i])
+		if dec.customTypes[typ] == nil {
+			dec.customTypes[typ] = &decodeCustomType{}
+		}
+		dec.customTypes[typ].ctxFn = fn
+	}
+	return dec
+}
+
+// DecodeContext is like Decode, with a context for the custom types
+// registered with RegisterCustomTypeContext and the ContextValidator types.
+// The decoding stops with the error of the context, such as
+// context.Canceled, when it's done.
+func (dec Decoder) DecodeContext(ctx context.Context, vs url.Values, dst interface{}) error {
+	main := reflect.ValueOf(dst)
+	if main.Kind() != reflect.Ptr {
+		return newError(ErrCodeNotAPointer, "", "", "dst %q is not a pointer", main.Kind())
+	}
+	dec.ctx = ctx
+	dec.main = main.Elem()
+	dec.values = vs
+	return dec.translate(dec.init())
+}
+
+// context returns the context of DecodeContext, or context.Background().
+func (dec *Decoder) context() context.Context {
+	if dec.ctx == nil {
+		return context.Background()
+	}
+	return dec.ctx
+}
+
+// fieldContext returns the context of the current field.
+func (dec *Decoder) fieldContext() FieldContext {
+	return FieldContext{
+		Context: dec.context(),
+		Path:    FormatPath(dec.segs),
+		Field:   dec.structField,
+		tagName: dec.opts.TagName,
+	}
+}
@@ -0,0 +1,145 @@
+package formam_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+type ctxKey struct{}
+
+func TestCustomTypeContext(t *testing.T) {
+	type Order struct {
+		Total Money   `formam:"total,currency=EUR"`
+		Lines []Money `formam:"lines,trim"`
+		Tips  map[string]Money
+	}
+
+	var fields []string
+	dec := formam.NewDecoder(nil).RegisterCustomTypeContext(func(ctx formam.FieldContext, vals []string) (interface{}, error) {
+		fields = append(fields, ctx.Path+" "+ctx.Field.Name+" "+strings.Join(ctx.Options(), "|"))
+		cur, ok := ctx.Option("currency")
+		if !ok {
+			cur, _ = ctx.Value(ctxKey{}).(string)
+		}
+		if vals[0] == "bad" {
+			return nil, errors.New("bad money")
+		}
+		return Money{Cents: int64(len(vals[0])), Currency: cur}, nil
+	}, []interface{}{Money{}})
+
+	var o Order
+	ctx := context.WithValue(context.Background(), ctxKey{}, "USD")
+	err := dec.DecodeContext(ctx, url.Values{
+		"total":     []string{"100"},
+		"lines[]":   []string{"1", "22"},
+		"Tips[bob]": []string{"333"},
+	}, &o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o.Total != (Money{3, "EUR"}) || len(o.Lines) != 2 || o.Lines[1] != (Money{2, "USD"}) || o.Tips["bob"] != (Money{3, "USD"}) {
+		t.Errorf("%+v", o)
+	}
+	want := []string{
+		"Tips[bob] Tips ",
+		"lines[0] Lines trim",
+		"lines[1] Lines trim",
+		"total Total currency=EUR",
+	}
+	if strings.Join(fields, "\n") != strings.Join(want, "\n") {
+		t.Errorf("\nout:  %q\nwant: %q", fields, want)
+	}
+
+	// Decode uses context.Background()
+	o = Order{}
+	if err := dec.Decode(url.Values{"Tips[bob]": []string{"1"}}, &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.Tips["bob"] != (Money{1, ""}) {
+		t.Errorf("%+v", o.Tips)
+	}
+
+	err = dec.Decode(url.Values{"total": []string{"bad"}}, &o)
+	var e *formam.Error
+	if !errors.As(err, &e) || e.Code() != formam.ErrCodeConversion || e.Path() != "total" || e.Cause().Error() != "could not decode field: bad money" {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+type tenantValidated struct {
+	Name   string
+	tenant string
+}
+
+func (v *tenantValidated) ValidateContext(ctx context.Context) error {
+	v.tenant, _ = ctx.Value(ctxKey{}).(string)
+	if v.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+// Validate is not called if ValidateContext exists.
+func (v *tenantValidated) Validate() error {
+	return errors.New("Validate is called")
+}
+
+func TestDecodeContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	dec := formam.NewDecoder(nil).RegisterCustomTypeContext(func(ctx formam.FieldContext, vals []string) (interface{}, error) {
+		calls++
+		cancel()
+		return Money{}, nil
+	}, []interface{}{Money{}})
+
+	s := struct {
+		A, B, C Money
+	}{}
+	err := dec.DecodeContext(ctx, url.Values{"A": {"1"}, "B": {"2"}, "C": {"3"}}, &s)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("wrong error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("%d calls after the cancellation", calls)
+	}
+
+	err = formam.NewDecoder(nil).DecodeContext(ctx, url.Values{"A.Cents": {"1"}}, &s)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestContextValidator(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "acme")
+
+	var v tenantValidated
+	err := formam.NewDecoder(nil).DecodeContext(ctx, url.Values{"Name": {"Homer"}}, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.tenant != "acme" {
+		t.Errorf("tenant is %q", v.tenant)
+	}
+
+	s := struct {
+		Users []tenantValidated
+	}{}
+	err = formam.NewDecoder(nil).Decode(url.Values{"Users[1].Name": {""}}, &s)
+	if e, ok := err.(*formam.Error); !ok || e.Code() != formam.ErrCodeValidation || e.Path() != "Users[1]" {
+		t.Errorf("wrong error: %v", err)
+	}
+}
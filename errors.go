@@ -3,25 +3,82 @@ package formam
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 )
 
+// ErrorCode is the kind of an Error.
+type ErrorCode uint8
+
 // Error codes.
 const (
-	ErrCodeNotAPointer  uint8 = iota // Didn't pass a pointer to Decode().
-	ErrCodeArrayIndex                // Error attempting to use an array index (e.g. foo[2]).
-	ErrCodeConversion                // Error converting field to the type.
-	ErrCodeUnknownType               // Unknown type.
-	ErrCodeUnknownField              // No struct field for passed parameter (will never be used if IgnoreUnknownKeys is set).
-	ErrCodeRange                     // Number is out of range (e.g. parsing 300 in uint8 would overflow).
-	ErrCodeArraySize                 // Array longer than MaxSize.
+	ErrCodeNotAPointer  ErrorCode = iota // Didn't pass a pointer to Decode().
+	ErrCodeArrayIndex                    // Error attempting to use an array index (e.g. foo[2]).
+	ErrCodeConversion                    // Error converting field to the type.
+	ErrCodeUnknownType                   // Unknown type.
+	ErrCodeUnknownField                  // No struct field for passed parameter (will never be used if IgnoreUnknownKeys is set).
+	ErrCodeRange                         // Number is out of range (e.g. parsing 300 in uint8 would overflow).
+	ErrCodeArraySize                     // Array longer than MaxSize.
+	ErrCodeSyntax                        // Malformed path or application/x-www-form-urlencoded data.
+	ErrCodeFormSize                      // Form longer than MaxFormSize.
+	ErrCodeValidation                    // Validate method returned an error.
+	ErrCodeNotAllowed                    // Field not allowed by Allow, Deny or Groups (only with RejectDisallowed).
+	ErrCodeArrayLength                   // Number of values doesn't fit the array length (see ArrayLengthPolicy).
+	ErrCodePanic                         // Panic while decoding (will never be used if DisablePanicRecovery is set).
 )
 
+// errorCodes are the names of the error codes, for String and JSON.
+var errorCodes = [...]string{
+	ErrCodeNotAPointer:  "not_a_pointer",
+	ErrCodeArrayIndex:   "array_index",
+	ErrCodeConversion:   "conversion",
+	ErrCodeUnknownType:  "unknown_type",
+	ErrCodeUnknownField: "unknown_field",
+	ErrCodeRange:        "range",
+	ErrCodeArraySize:    "array_size",
+	ErrCodeSyntax:       "syntax",
+	ErrCodeFormSize:     "form_size",
+	ErrCodeValidation:   "validation",
+	ErrCodeNotAllowed:   "not_allowed",
+	ErrCodeArrayLength:  "array_length",
+	ErrCodePanic:        "panic",
+}
+
+// String returns the name of the code, such as "conversion".
+func (c ErrorCode) String() string {
+	if int(c) < len(errorCodes) {
+		return errorCodes[c]
+	}
+	return "ErrorCode(" + strconv.Itoa(int(c)) + ")"
+}
+
+// MarshalText implements the interface TextMarshaler, with the name of the
+// code.
+func (c ErrorCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements the interface TextUnmarshaler, from the name of
+// the code.
+func (c *ErrorCode) UnmarshalText(text []byte) error {
+	for i, name := range errorCodes {
+		if name == string(text) {
+			*c = ErrorCode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("formam: unknown error code %q", text)
+}
+
 // Error indicates a error produced
 type Error struct {
-	code        uint8
+	code        ErrorCode
 	field, path string
+	value       string
+	typ         reflect.Type
 	err         error
+	tr          Translator
 }
 
 func (s *Error) Error() string {
@@ -41,24 +98,125 @@ func (s *Error) Error() string {
 
 // MarshalJSON implements the interface Marshaler
 func (s Error) MarshalJSON() ([]byte, error) {
-	return json.Marshal(s.Error())
+	e := struct {
+		Code    ErrorCode `json:"code"`
+		Field   string    `json:"field,omitempty"`
+		Path    string    `json:"path,omitempty"`
+		Value   string    `json:"value,omitempty"`
+		Type    string    `json:"type,omitempty"`
+		Message string    `json:"message"`
+	}{
+		Code:    s.code,
+		Field:   s.field,
+		Path:    s.path,
+		Value:   s.value,
+		Message: s.Message(),
+	}
+	if s.typ != nil {
+		e.Type = s.typ.String()
+	}
+	return json.Marshal(e)
+}
+
+// Message for the users of a form, in the language chosen with the Language
+// option or method. See RegisterTranslator.
+func (s Error) Message() string {
+	if s.tr != nil {
+		if msg := s.tr(&s); msg != "" {
+			return msg
+		}
+	}
+	return English(&s)
 }
 
 // Code for this error. See the ErrCode* constants.
-func (s Error) Code() uint8 {
+func (s Error) Code() ErrorCode {
 	return s.code
 }
 
+// Field for this error; the last field or map key in the path.
+func (s Error) Field() string {
+	return s.field
+}
+
 // Path for this error.
 func (s Error) Path() string {
 	return s.path
 }
 
+// Value that couldn't be decoded, or the first one if there are several.
+func (s Error) Value() string {
+	return s.value
+}
+
+// Type that the value was decoded into, or nil if not known.
+func (s Error) Type() reflect.Type {
+	return s.typ
+}
+
+// Unwrap returns the underlying error, such as a *strconv.NumError, for
+// errors.Is and errors.As.
+func (s *Error) Unwrap() error {
+	return s.err
+}
+
 // Cause implements the causer interface from github.com/pkg/errors.
 func (s *Error) Cause() error {
 	return s.err
 }
 
-func newError(code uint8, field, path, format string, a ...interface{}) error {
+func newError(code ErrorCode, field, path, format string, a ...interface{}) error {
 	return &Error{code: code, field: field, path: path, err: fmt.Errorf(format, a...)}
 }
+
+// Errors are the errors of all the keys that couldn't be decoded, returned
+// with the CollectErrors option.
+type Errors []*Error
+
+func (s Errors) Error() string {
+	var b strings.Builder
+	for i, err := range s {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// panicError returns the error for the value r recovered from a panic in the
+// current path.
+func (dec *Decoder) panicError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return dec.newError(ErrCodePanic, "panic: %w", err)
+	}
+	return dec.newError(ErrCodePanic, "panic: %v", r)
+}
+
+// isNotAllowed reports if err is an error with ErrCodeNotAllowed.
+func isNotAllowed(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.code == ErrCodeNotAllowed
+}
+
+// collect adds err to errs if it's an *Error and the CollectErrors option is
+// set, and otherwise returns it.
+func (dec *Decoder) collect(errs *Errors, err error) error {
+	if e, ok := err.(*Error); ok && dec.opts.CollectErrors {
+		*errs = append(*errs, e)
+		return nil
+	}
+	return err
+}
+
+// newError returns an error for the current field, with its value and type.
+func (dec *Decoder) newError(code ErrorCode, format string, a ...interface{}) error {
+	err := &Error{code: code, field: dec.field, path: dec.path, err: fmt.Errorf(format, a...)}
+	if len(dec.currValues) > 0 {
+		err.value = dec.currValues[0]
+	}
+	if dec.curr.IsValid() {
+		err.typ = dec.curr.Type()
+	}
+	return err
+}
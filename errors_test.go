@@ -2,7 +2,12 @@ package formam
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -14,10 +19,13 @@ func TestError(t *testing.T) {
 	}{
 		{newError(0, "", "", "oh noes"),
 			"formam: oh noes",
-			`"formam: oh noes"`},
+			`{"code":"not_a_pointer","message":"the destination is not a pointer"}`},
 		{newError(0, "foo", "foo.bar", "oh noes"),
 			"formam: field=foo; path=foo.bar: oh noes",
-			`"formam: field=foo; path=foo.bar: oh noes"`},
+			`{"code":"not_a_pointer","field":"foo","path":"foo.bar","message":"the destination is not a pointer"}`},
+		{&Error{code: ErrCodeConversion, field: "age", path: "people[2].age", value: "abc", typ: reflect.TypeOf(0), err: fmt.Errorf("oh noes")},
+			"formam: field=age; path=people[2].age: oh noes",
+			`{"code":"conversion","field":"age","path":"people[2].age","value":"abc","type":"int","message":"\"abc\" is not a valid whole number"}`},
 	}
 
 	for i, tt := range tests {
@@ -39,3 +47,124 @@ func TestError(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorValue(t *testing.T) {
+	s := struct {
+		People []struct {
+			Age  uint8
+			Text textError
+		}
+	}{}
+
+	tests := []struct {
+		key, value string
+		code       ErrorCode
+		path       string
+		typ        reflect.Type
+		cause      error
+	}{
+		{"People[2].Age", "abc", ErrCodeConversion, "People[2].Age", reflect.TypeOf(uint8(0)), strconv.ErrSyntax},
+		{"People[0].Age", "300", ErrCodeRange, "People[0].Age", reflect.TypeOf(uint8(0)), strconv.ErrRange},
+		{"People[0].Text", "x", ErrCodeConversion, "People[0].Text", reflect.TypeOf(textError(0)), errText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := NewDecoder(nil).Decode(url.Values{tt.key: []string{tt.value}}, &s)
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("not an *Error: %v", err)
+			}
+			if e.Code() != tt.code || e.Path() != tt.path || e.Value() != tt.value || e.Type() != tt.typ {
+				t.Errorf("code=%d path=%q value=%q type=%v", e.Code(), e.Path(), e.Value(), e.Type())
+			}
+			if !errors.Is(err, tt.cause) {
+				t.Errorf("%v is not %v", err, tt.cause)
+			}
+		})
+	}
+
+	err := NewDecoder(nil).Decode(url.Values{"People[0].Age": []string{"x"}}, &s)
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) || numErr.Num != "x" {
+		t.Errorf("no *strconv.NumError in %v", err)
+	}
+}
+
+var errText = errors.New("bad text")
+
+type textError int
+
+func (t *textError) UnmarshalText([]byte) error {
+	return errText
+}
+
+func TestErrorCode(t *testing.T) {
+	for c := ErrCodeNotAPointer; c <= ErrCodePanic; c++ {
+		text, err := c.MarshalText()
+		if err != nil || string(text) == "" {
+			t.Fatalf("%d: %q, %v", c, text, err)
+		}
+		var c2 ErrorCode
+		if err := c2.UnmarshalText(text); err != nil || c2 != c {
+			t.Errorf("%d: %d, %v", c, c2, err)
+		}
+	}
+	if s := ErrCodeConversion.String(); s != "conversion" {
+		t.Errorf("ErrCodeConversion is %q", s)
+	}
+	if s := ErrorCode(200).String(); s != "ErrorCode(200)" {
+		t.Errorf("ErrorCode(200) is %q", s)
+	}
+	var c ErrorCode
+	if err := c.UnmarshalText([]byte("nope")); err == nil {
+		t.Error("no error for an unknown code")
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	s := struct {
+		Name   string
+		Age    int
+		Scores []uint8
+		Tags   map[int]string
+	}{}
+	vals := url.Values{
+		"Name":      []string{"Homer"},
+		"Age":       []string{"x"},
+		"Scores[0]": []string{"1"},
+		"Scores[1]": []string{"300"},
+		"Tags[x]":   []string{"a"},
+		"Unknown":   []string{"b"},
+	}
+
+	err := NewDecoder(&DecoderOptions{CollectErrors: true}).Decode(vals, &s)
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("not Errors: %#v", err)
+	}
+	if s.Name != "Homer" || len(s.Scores) != 2 || s.Scores[0] != 1 {
+		t.Errorf("the valid keys are not decoded: %+v", s)
+	}
+
+	j, err := json.Marshal(errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[` +
+		`{"code":"conversion","field":"Age","path":"Age","value":"x","type":"int","message":"\"x\" is not a valid whole number"},` +
+		`{"code":"range","field":"Scores","path":"Scores[1]","value":"300","type":"uint8","message":"\"300\" is out of range"},` +
+		`{"code":"unknown_field","field":"Unknown","path":"Unknown","value":"b","type":"struct { Name string; Age int; Scores []uint8; Tags map[int]string }","message":"Unknown is not a known field"},` +
+		`{"code":"conversion","field":"Tags[x]","path":"Tags[x]","value":"x","type":"int","message":"\"x\" is not a valid whole number"}` +
+		`]`
+	if string(j) != want {
+		t.Errorf("\nout:  %s\nwant: %s", j, want)
+	}
+	if n := len(strings.Split(errs.Error(), "\n")); n != 4 {
+		t.Errorf("%d lines in %q", n, errs.Error())
+	}
+
+	err = NewDecoder(nil).Decode(vals, &s)
+	if e, ok := err.(*Error); !ok || e.Code() != ErrCodeConversion || e.Field() != "Age" {
+		t.Errorf("not the first error: %#v", err)
+	}
+}
@@ -2,27 +2,43 @@
 package formam
 
 import (
+	"context"
 	"encoding"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"math"
 	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Default options.
 const (
-	tagName = "formam"
-	maxSize = 16000
+	tagName     = "formam"
+	maxSize     = 16000
+	maxFormSize = 10 << 20
 )
 
+// errSkip is returned when the current path must be skipped without errors.
+var errSkip = errors.New("formam: skip path")
+
+// errPending is returned when the discriminator of a union is not read yet
+// by DecodeReader, so the key is decoded when all the keys are read.
+var errPending = errors.New("formam: pending path")
+
 // pathMap holds the values of a map with its key and values correspondent
 type pathMap struct {
-	field reflect.Value // map
-	key   string        // key of map
-	value reflect.Value // value of map
-	path  string        // form's path associated to map
+	field  reflect.Value // map
+	key    string        // key of map
+	value  reflect.Value // value of map
+	path   string        // form's path associated to map
+	mapKey reflect.Value // decoded key, once the value is set in the map
 }
 
 // pathMaps holds the values for each key
@@ -38,18 +54,30 @@ func (m pathMaps) find(id reflect.Value, key string) *pathMap {
 	return nil
 }
 
+// pathInterface holds a copy of the value of an interface to put it back
+// in the interface
+type pathInterface struct {
+	field reflect.Value // interface
+	value reflect.Value // addressable copy of the value in the interface
+}
+
 // DecodeCustomTypeFunc for decoding a custom type.
 type DecodeCustomTypeFunc func([]string) (interface{}, error)
 
-// decodeCustomTypeField is registered for a specific field.
+// decodeCustomTypeField is registered for a specific field: a value, the
+// paths that match a pattern, or a struct field.
 type decodeCustomTypeField struct {
-	fn    DecodeCustomTypeFunc
-	field reflect.Value
+	fn          DecodeCustomTypeFunc
+	field       reflect.Value
+	pattern     []PathSegment
+	structField *reflect.StructField
+	structType  reflect.Type // struct type that declares structField
 }
 
 // decodeCustomType fields for custom types.
 type decodeCustomType struct {
 	fn     DecodeCustomTypeFunc
+	ctxFn  DecodeCustomTypeContextFunc
 	fields []*decodeCustomTypeField
 }
 
@@ -57,19 +85,56 @@ type decodeCustomType struct {
 type Decoder struct {
 	main   reflect.Value   // reflect value of main struct/slice to decode
 	values url.Values      // all values of form
+	keys   []string        // keys of values in the order to decode them
 	opts   *DecoderOptions // options
+	ctx    context.Context // context of DecodeContext
 
 	curr       reflect.Value // current field (as reflect value)
 	currValues []string      // values of current path to decode
 
-	path  string // current path
-	field string // current field (as string)
-	index string // current index/key of a field: slice/array/map
+	structField reflect.StructField // struct field of the current path
+	structType  reflect.Type        // struct type that declares structField
+
+	path     string        // current path
+	pathSegs []PathSegment // segments of the current path
+	keySegs  []PathSegment // segments of the current key
+	segs     []PathSegment // segments of the current path walked so far
+	names    []pathName    // names of the segments walked so far, for Allow and Deny
+	steps    []valueStep   // steps to the values of segs, for Validate
+	step     valueStep     // step of the segment traversed
+	field    string        // current field (as string)
+	index    string        // current index/key of a field: slice/array/map
 	//isKey   bool
 
-	maps pathMaps // maps cached (it's decoded to the end)
+	maps   pathMaps        // maps cached (it's decoded to the end)
+	ifaces []pathInterface // interfaces to set when the current path is decoded
+
+	validations []validation   // values to validate when all is decoded
+	touched     map[string]int // index in validations by path
 
 	customTypes map[reflect.Type]*decodeCustomType // custom types registered
+	unions      map[reflect.Type]*union            // unions registered
+	transforms  map[string]TransformFunc           // transforms registered
+
+	numberFormats map[string]NumberFormat        // number formats registered
+	translators   map[string]Translator          // translators registered by language
+	keyDecoders   map[reflect.Type]DecodeKeyFunc // map key decoders registered
+
+	allow, deny [][]PathSegment // path patterns of Allow and Deny
+	groups      []string        // groups of Groups
+
+	collections  map[string]int    // length of the slices before decoding by path, for the Collections option
+	offset       int               // index of the first element added to the current slice with AppendCollections
+	unmarshaled  map[string]bool   // paths decoded by UnmarshalForm
+	unmarshalers []formUnmarshaler // values to call UnmarshalForm when all is decoded
+
+	flat      bool             // the keys are the paths of a flat map, like url.Values
+	errs      Errors           // errors collected with the CollectErrors option
+	streaming bool             // the keys are decoded as DecodeReader reads them
+	skip      int              // number of values of the current key decoded before
+	skipped   map[string]bool  // keys not decoded again when they are repeated
+	pending   []pendingKey     // keys to decode when all is read
+	short     map[string]error // errors of the arrays with too few values so far, for ArrayLengthExact
 }
 
 // DecoderOptions options for decoding the values.
@@ -83,6 +148,31 @@ type DecoderOptions struct {
 	// Disable UnmarshalText interface
 	DisableUnmarshalText bool
 
+	// Parser for the paths in the form keys; default is DefaultPathParser.
+	PathParser PathParser
+
+	// Allow brackets to select struct fields as well as slice indexes and
+	// map keys, as in user[address][city] produced by PHP and the qs and
+	// jQuery.param JavaScript libraries. This is always allowed with the
+	// BracketPathParser.
+	BracketFields bool
+
+	// Build the values of nil interface{} fields which have children in the
+	// path: a map[string]interface{} for fields and keys, and an
+	// []interface{} for indexes; e.g. Extra.a[0].b=1 sets Extra to
+	// map[a:[map[b:1]]]. The values are strings, unless SniffInterfaceValues
+	// is set.
+	//
+	// This is always enabled when decoding in a map[string]interface{} or an
+	// interface{}.
+	GenericInterfaces bool
+
+	// Decode the values of interface{} fields that look like a bool or number
+	// as a bool, an int64 or a float64 instead of a string. A number is only
+	// decoded if it's written as Go formats it, so 01234 or 1.50 are kept as
+	// strings.
+	SniffInterfaceValues bool
+
 	// Ignore unknown form fields. By default unknown fields are an error
 	// (although all valid keys will still be decoded).
 	IgnoreUnknownKeys bool
@@ -94,6 +184,50 @@ type DecoderOptions struct {
 	// The default is 16,000; set to -1 to disable.
 	MaxSize int
 
+	// The maximum number of bytes that DecodeString and DecodeReader will
+	// read.
+	//
+	// The default is 10MB; set to -1 to disable.
+	MaxFormSize int64
+
+	// Format of the numbers for int, uint and float fields; e.g.
+	// &NumberFormatGerman to decode 1.234,56. The "number" tag option
+	// overrides it for a field; see RegisterNumberFormat. Default is the
+	// syntax of the strconv package.
+	NumberFormat *NumberFormat
+
+	// Decode integers with the prefixes of Go: 0x for hexadecimal, 0o or 0
+	// for octal and 0b for binary, and with underscores between the digits
+	// (e.g. 0xFF or 1_000). The "base" tag option overrides it for a field,
+	// with a base between 2 and 36, or 0 for the prefixes.
+	IntegerPrefixes bool
+
+	// How the values are set in the slices and maps that already have
+	// elements, such as a struct loaded from a database. Default is
+	// MergeCollections.
+	Collections CollectionMode
+
+	// What to do when the number of values of a key without index doesn't
+	// fit the length of an array, like a=1&a=2&a=3 for [2]int. Default is
+	// ArrayLengthError.
+	ArrayLength ArrayLengthPolicy
+
+	// Don't turn the panics while decoding into errors with ErrCodePanic,
+	// e.g. to get the stack trace while debugging a custom type.
+	DisablePanicRecovery bool
+
+	// Return an error with ErrCodeNotAllowed for the keys disallowed by the
+	// Allow, Deny and Groups methods, instead of skipping them.
+	RejectDisallowed bool
+
+	// Decode all the keys and return the errors of the ones that couldn't be
+	// decoded as Errors, instead of returning the first error.
+	CollectErrors bool
+
+	// Language of the messages of the errors, registered with
+	// RegisterTranslator. Default is English.
+	Language string
+
 	// Timeformats to try for time.Time fields; the first one that doesn't
 	// return an error for the field is used. Default is [2006-01-02].
 	TimeFormats []string
@@ -108,7 +242,12 @@ func (dec *Decoder) RegisterCustomType(fn DecodeCustomTypeFunc, types []interfac
 	for i := range types {
 		typ := reflect.TypeOf(types[i])
 		if dec.customTypes[typ] == nil {
-			dec.customTypes[typ] = &decodeCustomType{fn: fn, fields: make([]*decodeCustomTypeField, 0, lenFields)}
+			dec.customTypes[typ] = &decodeCustomType{fields: make([]*decodeCustomTypeField, 0, lenFields)}
+		}
+		// the type could have been added by the other Register methods
+		// without a function for all the fields
+		if lenFields == 0 || dec.customTypes[typ].fn == nil {
+			dec.customTypes[typ].fn = fn
 		}
 		if lenFields > 0 {
 			for j := range fields {
@@ -121,6 +260,99 @@ func (dec *Decoder) RegisterCustomType(fn DecodeCustomTypeFunc, types []interfac
 	return dec
 }
 
+// RegisterCustomTypePath registers a function for decoding custom types in
+// the fields with a path that matches one of the patterns, in any decoded
+// value. The patterns are paths where a * matches any index, map key or
+// field name; e.g. "Events[*].Start" for the Start field of all the events.
+// The struct fields match like in the patterns of Allow: by their name or the
+// name in their tag, however the key writes them.
+//
+// It panics if a pattern can't be parsed by the PathParser.
+func (dec *Decoder) RegisterCustomTypePath(fn DecodeCustomTypeFunc, types []interface{}, patterns []string) *Decoder {
+	for _, p := range patterns {
+		segs, err := dec.opts.PathParser.ParsePath(p)
+		if err != nil {
+			panic(fmt.Sprintf("formam: invalid path pattern %q: %s", p, err))
+		}
+		for i := range types {
+			dec.addCustomTypeField(reflect.TypeOf(types[i]), &decodeCustomTypeField{fn: fn, pattern: segs})
+		}
+	}
+	return dec
+}
+
+// RegisterCustomTypeStructField registers a function for decoding custom
+// types in the fields of the struct strct, in any decoded value; including
+// the elements of the fields with a slice or map. The fields are the ones of
+// strct, or of its embedded structs, for example with:
+//
+//	field, _ := reflect.TypeOf(Event{}).FieldByName("Start")
+//	dec.RegisterCustomTypeStructField(fn, []interface{}{time.Time{}}, Event{}, []reflect.StructField{field})
+//
+// It panics if a field is not in strct.
+func (dec *Decoder) RegisterCustomTypeStructField(fn DecodeCustomTypeFunc, types []interface{}, strct interface{}, fields []reflect.StructField) *Decoder {
+	for j := range fields {
+		st := declaringStruct(reflect.TypeOf(strct), fields[j])
+		for i := range types {
+			dec.addCustomTypeField(reflect.TypeOf(types[i]), &decodeCustomTypeField{fn: fn, structField: &fields[j], structType: st})
+		}
+	}
+	return dec
+}
+
+// declaringStruct returns the struct type that declares the field of typ,
+// which is an embedded struct for a promoted field. It panics if the field
+// is not in typ.
+func declaringStruct(typ reflect.Type, field reflect.StructField) reflect.Type {
+	t := typ
+	for i, index := range field.Index {
+		if t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct || index >= t.NumField() {
+			break
+		}
+		if i == len(field.Index)-1 {
+			if t.Field(index).Name == field.Name {
+				return t
+			}
+			break
+		}
+		t = t.Field(index).Type
+	}
+	panic(fmt.Sprintf("formam: %s is not a field of %v", field.Name, typ))
+}
+
+// addCustomTypeField adds a function for a specific field of the type typ.
+func (dec *Decoder) addCustomTypeField(typ reflect.Type, field *decodeCustomTypeField) {
+	if dec.customTypes == nil {
+		dec.customTypes = make(map[reflect.Type]*decodeCustomType, 100)
+	}
+	if dec.customTypes[typ] == nil {
+		dec.customTypes[typ] = &decodeCustomType{}
+	}
+	dec.customTypes[typ].fields = append(dec.customTypes[typ].fields, field)
+}
+
+// ArrayLengthPolicy is what to do when the number of values doesn't fit the
+// length of an array.
+type ArrayLengthPolicy uint8
+
+// Array length policies.
+const (
+	// ArrayLengthError returns an error with ErrCodeArrayLength for more
+	// values than the length of the array; the elements without values are
+	// not changed.
+	ArrayLengthError ArrayLengthPolicy = iota
+
+	// ArrayLengthTruncate ignores the values after the length of the array.
+	ArrayLengthTruncate
+
+	// ArrayLengthExact returns an error with ErrCodeArrayLength if the
+	// number of values is not the length of the array.
+	ArrayLengthExact
+)
+
 // NewDecoder creates a new instance of Decoder.
 func NewDecoder(opts *DecoderOptions) *Decoder {
 	dec := &Decoder{opts: opts}
@@ -130,9 +362,15 @@ func NewDecoder(opts *DecoderOptions) *Decoder {
 	if dec.opts.TagName == "" {
 		dec.opts.TagName = tagName
 	}
+	if dec.opts.PathParser == nil {
+		dec.opts.PathParser = DefaultPathParser{}
+	}
 	if dec.opts.MaxSize == 0 {
 		dec.opts.MaxSize = maxSize
 	}
+	if dec.opts.MaxFormSize == 0 {
+		dec.opts.MaxFormSize = maxFormSize
+	}
 	if len(dec.opts.TimeFormats) == 0 {
 		dec.opts.TimeFormats = []string{"2006-01-02"}
 	}
@@ -141,14 +379,13 @@ func NewDecoder(opts *DecoderOptions) *Decoder {
 
 // Decode the url.Values and populate the destination dst, which must be a
 // pointer.
+//
+// A map[string]interface{} or interface{} destination gets a generic tree
+// of maps and slices, as with the GenericInterfaces option, and a
+// map[string]string or map[string][]string destination gets the paths as
+// keys, like url.Values.
 func (dec Decoder) Decode(vs url.Values, dst interface{}) error {
-	main := reflect.ValueOf(dst)
-	if main.Kind() != reflect.Ptr {
-		return newError(ErrCodeNotAPointer, "", "", "dst %q is not a pointer", main.Kind())
-	}
-	dec.main = main.Elem()
-	dec.values = vs
-	return dec.init()
+	return dec.DecodeContext(context.Background(), vs, dst)
 }
 
 // Decode the url.Values and populate the destination dst, which must be a
@@ -162,8 +399,9 @@ func Decode(vs url.Values, dst interface{}) error {
 		main:   main.Elem(),
 		values: vs,
 		opts: &DecoderOptions{
-			TagName: tagName,
-			MaxSize: maxSize,
+			TagName:    tagName,
+			PathParser: DefaultPathParser{},
+			MaxSize:    maxSize,
 		},
 	}
 	return dec.init()
@@ -171,187 +409,357 @@ func Decode(vs url.Values, dst interface{}) error {
 
 // init initializes the decoding
 func (dec Decoder) init() error {
-	// iterate over the form's values and decode it
-	for k, v := range dec.values {
-		dec.path = k
-		dec.currValues = v
-		dec.curr = dec.main
-		if err := dec.analyzePath(); err != nil {
-			if dec.curr.Kind() == reflect.Struct && dec.opts.IgnoreUnknownKeys {
-				continue
+	// decode the keys in a defined order, so that errors and the side effects
+	// of custom types and UnmarshalText are reproducible
+	if dec.keys == nil {
+		dec.keys = sortedKeys(dec.values)
+	}
+	return dec.run(func() error {
+		for _, k := range dec.keys {
+			if err := dec.decodePath(k); err != nil {
+				return err
 			}
-			return err
 		}
+		return nil
+	})
+}
+
+// run calls decodeKeys to decode the keys, and then sets the values of the
+// maps, calls UnmarshalForm and Validate, and returns the errors collected.
+func (dec *Decoder) run(decodeKeys func() error) (err error) {
+	// a malformed form must not crash the program
+	if !dec.opts.DisablePanicRecovery {
+		defer func() {
+			if r := recover(); r != nil {
+				err = dec.panicError(r)
+			}
+		}()
+	}
+
+	dec.pathSegs = make([]PathSegment, 0, 4)
+	dec.segs = make([]PathSegment, 0, 4)
+
+	// a map[string]interface{} or an interface{} is decoded as a generic
+	// tree, and a map[string]string or a map[string][]string, like
+	// url.Values, gets the paths as keys
+	switch typ := dec.main.Type(); typ.Kind() {
+	case reflect.Interface:
+		dec.genericInterfaces(typ)
+	case reflect.Map:
+		if typ.Key().Kind() != reflect.String {
+			break
+		}
+		switch elem := typ.Elem(); elem.Kind() {
+		case reflect.Interface:
+			dec.genericInterfaces(elem)
+		case reflect.String:
+			dec.flat = true
+		case reflect.Slice:
+			dec.flat = elem.Elem().Kind() == reflect.String
+		}
+	}
+
+	if err := decodeKeys(); err != nil {
+		return err
 	}
+	dec.skip = 0
 
 	// set values of maps
 	for _, v := range dec.maps {
-		key := v.field.Type().Key()
-		ptr := false
-		// check if the key implements the UnmarshalText interface
-		var val reflect.Value
-		if key.Kind() == reflect.Ptr {
-			ptr = true
-			val = reflect.New(key.Elem())
-		} else {
-			val = reflect.New(key).Elem()
-		}
+		key := reflect.New(v.field.Type().Key()).Elem()
 		// decode key
 		dec.path = v.path
 		dec.field = v.path
 		dec.currValues = []string{v.key}
-		dec.curr = val
-		//dec.isKey = true
-		if err := dec.decode(); err != nil {
-			return err
-		}
-		// check if the key is a pointer or not. And if it is, then get its address
-		if ptr && dec.curr.Kind() != reflect.Ptr {
-			dec.curr = dec.curr.Addr()
+		dec.curr = key
+		dec.segs = dec.segs[:0]
+		dec.steps = dec.steps[:0]
+		dec.names = dec.names[:0]
+		dec.structField = reflect.StructField{}
+		dec.structType = nil
+		if err := dec.decodeKey(v.key); err != nil {
+			if err := dec.collect(&dec.errs, err); err != nil {
+				return err
+			}
+			continue
 		}
 		// set key with its value
-		v.field.SetMapIndex(dec.curr, v.value)
+		v.field.SetMapIndex(key, v.value)
+		v.mapKey = key
+	}
+
+	if err := dec.unmarshalForms(); err != nil {
+		return err
+	}
+	if err := dec.shortArrays(); err != nil {
+		return err
+	}
+	if err := dec.validate(&dec.errs); err != nil {
+		return err
+	}
+	if len(dec.errs) > 0 {
+		return dec.errs
 	}
 	return nil
 }
 
+// decodePath decodes the values of the key k, from the one at dec.skip. The
+// errors collected with the CollectErrors option are kept in dec.errs, and
+// the others are returned.
+func (dec *Decoder) decodePath(k string) error {
+	if err := dec.context().Err(); err != nil {
+		return err
+	}
+	dec.path = k
+	dec.currValues = dec.values[k][dec.skip:]
+	dec.curr = dec.main
+	dec.segs = dec.segs[:0]
+	dec.steps = dec.steps[:0]
+	dec.names = dec.names[:0]
+	dec.structField = reflect.StructField{}
+	dec.structType = nil
+	var err error
+	if dec.flat {
+		dec.keySegs = []PathSegment{{Kind: FieldSegment, Name: k}}
+		err = dec.walk(dec.keySegs)
+	} else {
+		err = dec.analyzePath()
+	}
+	if err == nil {
+		return nil
+	}
+	if err == errPending {
+		dec.pending = append(dec.pending, pendingKey{k, dec.skip})
+		dec.skipKey(k)
+		return nil
+	}
+	if dec.curr.Kind() == reflect.Struct && dec.opts.IgnoreUnknownKeys && !isNotAllowed(err) {
+		return nil
+	}
+	dec.skipKey(k)
+	return dec.collect(&dec.errs, err)
+}
+
+// skipKey skips the values of the key k read after the current ones.
+func (dec *Decoder) skipKey(k string) {
+	if dec.skipped == nil {
+		dec.skipped = make(map[string]bool)
+	}
+	dec.skipped[k] = true
+}
+
+// genericInterfaces enables the GenericInterfaces option if typ is an
+// interface{}.
+func (dec *Decoder) genericInterfaces(typ reflect.Type) {
+	if typ.NumMethod() > 0 || dec.opts.GenericInterfaces {
+		return
+	}
+	opts := *dec.opts
+	opts.GenericInterfaces = true
+	dec.opts = &opts
+}
+
+// sortedKeys returns the keys of vs sorted by path.
+func sortedKeys(vs url.Values) []string {
+	keys := make([]string, 0, len(vs))
+	for k := range vs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // analyzePath analyzes the current path to walk through it.
 // For example: users[0].name
-func (dec *Decoder) analyzePath() (err error) {
-	inBracket := false
-	bracketClosed := false
-	lastPos := 0
-	endPos := 0
-
-	// parse path
-	for i, char := range []byte(dec.path) {
-		if char == '[' && inBracket == false {
-			// found an opening bracket
-			bracketClosed = false
-			inBracket = true
-			dec.field = dec.path[lastPos:i]
-			lastPos = i + 1
-			continue
-		} else if inBracket {
-			// it is inside of bracket, so get its value
-			if char == ']' {
-				// found an closing bracket, so it will be recently close, so put as true the bracketClosed
-				// and put as false inBracket and pass the value of bracket to dec.key
-				inBracket = false
-				bracketClosed = true
-				if endPos == 0 { // foo[] without number.
-					dec.index = dec.path[lastPos:i]
-				} else {
-					dec.index = dec.path[lastPos:endPos]
-				}
-				lastPos = i + 1
-				// traverse the path
-				err = dec.traverse()
-				// flush the index already used by traverse
-				dec.index = ""
-				// check if the "traverse" failed
-				if err != nil {
-					return
+func (dec *Decoder) analyzePath() error {
+	var segs []PathSegment
+	var err error
+	if p, ok := dec.opts.PathParser.(pathAppender); ok {
+		segs, err = p.appendPath(dec.pathSegs[:0], dec.path)
+		dec.pathSegs = segs
+	} else {
+		segs, err = dec.opts.PathParser.ParsePath(dec.path)
+	}
+	if err != nil {
+		return newError(ErrCodeSyntax, "", dec.path, "could not parse path: %w", err)
+	}
+	dec.keySegs = segs
+	err = dec.walk(segs)
+
+	// put back the values traversed in interfaces, from the innermost
+	for i := len(dec.ifaces) - 1; i >= 0; i-- {
+		dec.ifaces[i].field.Set(dec.ifaces[i].value)
+	}
+	dec.ifaces = dec.ifaces[:0]
+	return err
+}
+
+// walk traverses the segments of the current path until the last one, and
+// decodes the value in it.
+func (dec *Decoder) walk(segs []PathSegment) error {
+	// position of segs in the segments of the key
+	pos := len(dec.keySegs) - len(segs)
+	for i, seg := range segs {
+		discriminator := false
+		if dec.curr.Kind() == reflect.Interface {
+			if u := dec.unions[dec.curr.Type()]; u != nil {
+				if err := dec.traverseUnion(u); err != nil {
+					return err
 				}
-			} else {
-				// still inside the bracket, so to save the end position
-				endPos = i + 1
+				discriminator = i == len(segs)-1 && seg.Name == u.key
 			}
-			continue
-		} else if !inBracket {
-			// not found any bracket, so try found a field
-			if char == '.' {
-				// found a field, we need to know if the field is next of a closing bracket,
-				// for example: [0].Field
-				if bracketClosed {
-					bracketClosed = false
-					lastPos = i + 1
-					continue
-				}
-				// found a field, but is not next of a closing bracket, for example: Field1.Field2
-				dec.field = dec.path[lastPos:i]
-				//dec.field = tmp[:i]
-				lastPos = i + 1
-				if err = dec.traverse(); err != nil {
-					return
+		}
+		dec.traverseInterface(seg)
+		dec.collection()
+		if ok, err := dec.unmarshalForm(pos + i); ok || err != nil {
+			return err
+		}
+
+		// an empty index followed by more segments, for example rows[][name],
+		// decodes every value in its own element of the slice
+		if seg.Kind != FieldSegment && seg.Name == "" && i < len(segs)-1 && dec.curr.Kind() == reflect.Slice {
+			return dec.walkRows(segs[i+1:])
+		}
+		// the struct fields are checked when they are found
+		field := dec.curr.Kind() == reflect.Struct && (seg.Kind == FieldSegment || seg.Name != "")
+		if !field {
+			if err := dec.allowed(i == len(segs)-1, pathName{name: seg.Name}); err != nil {
+				if err == errSkip {
+					return nil
 				}
+				return err
 			}
-			continue
+		}
+		if err := dec.traverse(seg, i == len(segs)-1); err != nil {
+			if err == errSkip {
+				return nil
+			}
+			// the concrete type doesn't need to have the discriminator
+			if e, ok := err.(*Error); ok && discriminator && e.code == ErrCodeUnknownField {
+				return nil
+			}
+			return err
+		}
+		// an empty index gets the concrete indexes in setValues
+		if seg.Kind == FieldSegment || seg.Name != "" {
+			dec.segs = append(dec.segs, seg)
+			dec.steps = append(dec.steps, dec.step)
+			if !field {
+				dec.names = append(dec.names, pathName{name: seg.Name})
+			}
+			dec.touch()
 		}
 	}
-
-	// last field of path, traverse it and decode the value in it
-	dec.field = dec.path[lastPos:]
-	if err := dec.traverse(); err != nil {
+	if ok, err := dec.unmarshalForm(len(dec.keySegs)); ok || err != nil {
 		return err
 	}
+	dec.transform()
+	dec.collection()
 	return dec.decode()
 }
 
-// Traverses the current path until to the last field.
-func (dec *Decoder) traverse() error {
-	//  If there is field ("foo.fieldname"), then it should be struct or map.
-	if dec.field != "" {
+// walkRows walks through the segments for each value of the current path in
+// the element of the current slice with the same position.
+func (dec *Decoder) walkRows(segs []PathSegment) error {
+	slice, values, offset := dec.curr, dec.currValues, dec.offset+dec.skip
+	dec.skip = 0
+	if err := dec.expandSlice(offset + len(values)); err != nil {
+		return dec.newError(ErrCodeArraySize, "%w", err)
+	}
+	n, m := len(dec.segs), len(dec.names)
+	for i := range values {
+		dec.segs = append(dec.segs[:n], PathSegment{Kind: IndexSegment, Name: strconv.Itoa(offset + i)})
+		dec.steps = append(dec.steps[:n], valueStep{index: offset + i})
+		dec.names = append(dec.names[:m], pathName{name: dec.segs[n].Name})
+		dec.curr = slice.Index(offset + i)
+		dec.currValues = values[i : i+1]
+		dec.traverseIndirect()
+		if err := dec.walk(segs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Traverses the segment of the current path, the last one if last is true.
+func (dec *Decoder) traverse(seg PathSegment, last bool) error {
+	dec.step = valueStep{index: -1}
+
+	//  If it is a field ("foo.fieldname"), then it should be struct or map.
+	if seg.Kind == FieldSegment {
+		dec.field = seg.Name
 		switch dec.curr.Kind() {
 		case reflect.Struct:
-			if err := dec.findStructField(); err != nil {
+			if err := dec.findStructField(last); err != nil {
 				return err
 			}
+			dec.step.field = dec.field
 		case reflect.Map:
 			// leave backward compatibility for access to maps by .
 			dec.traverseInMap(true)
 		}
-		dec.field = ""
 
 		dec.traverseIndirect()
+		return nil
 	}
 
-	// If there's an index ("foo[index]") then access the slice, array, or map.
-	if dec.index != "" {
-		switch dec.curr.Kind() {
-		case reflect.Array:
-			index, err := strconv.Atoi(dec.index)
-			if err != nil {
-				return newError(ErrCodeArrayIndex, dec.field, dec.path, "array index is not a number: %s", err)
-			}
-			if dec.curr.Len() <= index {
-				return newError(ErrCodeArrayIndex, dec.field, dec.path, "array index is out of bounds")
-			}
+	// If it is an index ("foo[index]") then access the slice, array, or map.
+	// An empty index ("foo[]") appends the values to the slice.
+	if seg.Name == "" {
+		return nil
+	}
+	dec.index = seg.Name
+	defer func() { dec.index = "" }()
+	switch dec.curr.Kind() {
+	case reflect.Array:
+		index, err := strconv.Atoi(dec.index)
+		if err != nil {
+			return dec.newError(ErrCodeArrayIndex, "array index is not a number: %w", err)
+		}
+		if index < 0 || dec.curr.Len() <= index {
+			return dec.newError(ErrCodeArrayIndex, "array index is out of bounds")
+		}
 
-			dec.curr = dec.curr.Index(index)
-		case reflect.Slice:
-			index, err := strconv.Atoi(dec.index)
+		dec.curr = dec.curr.Index(index)
+		dec.step.index = index
+	case reflect.Slice:
+		index, err := strconv.Atoi(dec.index)
+		if err != nil {
+			return dec.newError(ErrCodeArrayIndex, "slice index is not a number: %w", err)
+		}
+		if index < 0 {
+			return dec.newError(ErrCodeArrayIndex, "slice index is negative")
+		}
+		index += dec.offset
+		if dec.curr.Len() <= index {
+			err := dec.expandSlice(index + 1)
 			if err != nil {
-				return newError(ErrCodeArrayIndex, dec.field, dec.path, "slice index is not a number: %s", err)
+				return dec.newError(ErrCodeArraySize, "%w", err)
 			}
-			if dec.curr.Len() <= index {
-				err := dec.expandSlice(index + 1)
-				if err != nil {
-					return newError(ErrCodeArraySize, dec.field, dec.path, "%s", err)
-				}
-			}
-			dec.curr = dec.curr.Index(index)
-		case reflect.Map:
-			// leave backward compatibility for access to maps by .
-			dec.traverseInMap(false)
-		default:
-			return newError(ErrCodeArrayIndex, dec.field, dec.path, "has an array index but it is a %v", dec.curr.Kind())
 		}
-
-		dec.traverseIndirect()
+		dec.curr = dec.curr.Index(index)
+		dec.step.index = index
+	case reflect.Map:
+		dec.traverseInMap(false)
+	case reflect.Struct:
+		if seg.Kind != KeySegment && !dec.opts.BracketFields {
+			return dec.newError(ErrCodeArrayIndex, "has an array index but it is a %v", dec.curr.Kind())
+		}
+		dec.field = dec.index
+		if err := dec.findStructField(last); err != nil {
+			return err
+		}
+		dec.step.field = dec.field
+	default:
+		return dec.newError(ErrCodeArrayIndex, "has an array index but it is a %v", dec.curr.Kind())
 	}
 
+	dec.traverseIndirect()
 	return nil
 }
 
 // Resolve pointers to their concrete types.
 func (dec *Decoder) traverseIndirect() {
-	// check if is a interface and it is not nil. This mean that the interface
-	// has a struct, map or slice as value
-	if dec.curr.Kind() == reflect.Interface && !dec.curr.IsNil() {
-		dec.curr = dec.curr.Elem()
-	}
-
 	// check if it is a pointer
 	if dec.curr.Kind() == reflect.Ptr {
 		if dec.curr.IsNil() {
@@ -361,6 +769,54 @@ func (dec *Decoder) traverseIndirect() {
 	}
 }
 
+// traverseInterface puts in Decoder.curr the concrete value of the current
+// interface, so the segment seg can be traversed in it.
+func (dec *Decoder) traverseInterface(seg PathSegment) {
+	if dec.curr.Kind() != reflect.Interface {
+		return
+	}
+	if dec.curr.IsNil() {
+		if !dec.opts.GenericInterfaces || dec.curr.NumMethod() > 0 {
+			return
+		}
+		// build a generic value for the children of the path
+		if seg.Kind != FieldSegment && isIndex(seg.Name) {
+			dec.curr.Set(reflect.MakeSlice(typeInterfaceSlice, 0, 0))
+		} else {
+			dec.curr.Set(reflect.MakeMap(typeInterfaceMap))
+		}
+	}
+
+	elem := dec.curr.Elem()
+	switch elem.Kind() {
+	case reflect.Map:
+		dec.curr = elem
+	case reflect.Ptr:
+		if elem.IsNil() {
+			dec.curr.Set(reflect.New(elem.Type().Elem()))
+			elem = dec.curr.Elem()
+		}
+		dec.curr = elem.Elem()
+	default:
+		// the value in an interface can't be set, so traverse a copy and
+		// put it back in the interface when the path is decoded
+		v := reflect.New(elem.Type()).Elem()
+		v.Set(elem)
+		dec.ifaces = append(dec.ifaces, pathInterface{dec.curr, v})
+		dec.curr = v
+	}
+}
+
+// isIndex reports if the name is an index of a slice or empty.
+func isIndex(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '0' || name[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 // walkMap puts in Decoder.curr the map concrete for decode the current value
 func (dec *Decoder) traverseInMap(byField bool) {
 	n := dec.curr.Type()
@@ -370,10 +826,11 @@ func (dec *Decoder) traverseInMap(byField bool) {
 		}
 		val := reflect.New(n.Elem()).Elem()
 		if byField {
-			dec.maps = append(dec.maps, &pathMap{dec.curr, dec.field, val, dec.path})
+			dec.maps = append(dec.maps, &pathMap{field: dec.curr, key: dec.field, value: val, path: dec.path})
 		} else {
-			dec.maps = append(dec.maps, &pathMap{dec.curr, dec.index, val, dec.path})
+			dec.maps = append(dec.maps, &pathMap{field: dec.curr, key: dec.index, value: val, path: dec.path})
 		}
+		dec.step.m = dec.maps[len(dec.maps)-1]
 		dec.curr = val
 	}
 	if dec.curr.IsNil() {
@@ -392,6 +849,7 @@ func (dec *Decoder) traverseInMap(byField bool) {
 			// the key not exists
 			makeAndAppend()
 		} else {
+			dec.step.m = a
 			dec.curr = a.value
 		}
 	}
@@ -399,6 +857,10 @@ func (dec *Decoder) traverseInMap(byField bool) {
 
 // decode sets the value in the field
 func (dec *Decoder) decode() error {
+	if dec.skip > 0 && !dec.repeats() {
+		return nil
+	}
+
 	// if DisableUnmarshalText is true then only use customType if available
 	if dec.opts.DisableUnmarshalText {
 		if ok, err := dec.isCustomType(); ok || err != nil {
@@ -427,6 +889,9 @@ func (dec *Decoder) decode() error {
 	case reflect.Array:
 		if dec.index == "" {
 			// not has index, so to decode all values in the slice
+			if err := dec.arrayLength(); err != nil {
+				return err
+			}
 			if err := dec.setValues(); err != nil {
 				return err
 			}
@@ -434,18 +899,26 @@ func (dec *Decoder) decode() error {
 			// has index, so to decode value by index indicated
 			index, err := strconv.Atoi(dec.index)
 			if err != nil {
-				return newError(ErrCodeArrayIndex, dec.field, dec.path, "array index is not a number: %s", err)
+				return dec.newError(ErrCodeArrayIndex, "array index is not a number: %w", err)
+			}
+			if index < 0 || dec.curr.Len() <= index {
+				return dec.newError(ErrCodeArrayIndex, "array index is out of bounds")
 			}
 			dec.curr = dec.curr.Index(index)
 			return dec.decode()
 		}
 	case reflect.Slice:
+		if dec.index == "" && dec.curr.Type().Elem().Kind() == reflect.Uint8 {
+			if enc, ok := tagOption(dec.structField.Tag, dec.opts.TagName, "encoding"); ok {
+				return dec.decodeBytes(enc)
+			}
+		}
 		if dec.index == "" {
 			// not has index, so to decode all values in the slice
 			// only for slices
-			err := dec.expandSlice(len(dec.currValues))
+			err := dec.expandSlice(dec.offset + dec.skip + len(dec.currValues))
 			if err != nil {
-				return newError(ErrCodeArraySize, dec.field, dec.path, "%s", err)
+				return dec.newError(ErrCodeArraySize, "%w", err)
 			}
 			if err := dec.setValues(); err != nil {
 				return err
@@ -454,13 +927,13 @@ func (dec *Decoder) decode() error {
 			// has index, so to decode value by index indicated
 			index, err := strconv.Atoi(dec.index)
 			if err != nil {
-				return newError(ErrCodeArrayIndex, dec.field, dec.path, "slice index is not a number: %s", err)
+				return dec.newError(ErrCodeArrayIndex, "slice index is not a number: %w", err)
 			}
 			// only for slices
 			if dec.curr.Len() <= index {
 				err := dec.expandSlice(index + 1)
 				if err != nil {
-					return newError(ErrCodeArraySize, dec.field, dec.path, "%s", err)
+					return dec.newError(ErrCodeArraySize, "%w", err)
 				}
 			}
 			dec.curr = dec.curr.Index(index)
@@ -469,35 +942,57 @@ func (dec *Decoder) decode() error {
 	case reflect.String:
 		dec.curr.SetString(dec.currValues[0])
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		num, err := strconv.ParseInt(dec.currValues[0], 10, dec.curr.Type().Bits())
+		v, base, err := dec.integer(dec.currValues[0])
+		if err != nil {
+			return err
+		}
+		num, err := strconv.ParseInt(v, base, dec.curr.Type().Bits())
 		if err != nil {
 			code := ErrCodeConversion
 			if err, ok := err.(*strconv.NumError); ok && err.Err == strconv.ErrRange {
 				code = ErrCodeRange
 			}
-			return newError(code, dec.field, dec.path, "could not parse number: %s", err)
+			return dec.newError(code, "could not parse number: %w", err)
 		}
 		dec.curr.SetInt(num)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		num, err := strconv.ParseUint(dec.currValues[0], 10, dec.curr.Type().Bits())
+		v, base, err := dec.integer(dec.currValues[0])
+		if err != nil {
+			return err
+		}
+		num, err := strconv.ParseUint(v, base, dec.curr.Type().Bits())
 		if err != nil {
 			code := ErrCodeConversion
 			if err, ok := err.(*strconv.NumError); ok && err.Err == strconv.ErrRange {
 				code = ErrCodeRange
 			}
-			return newError(code, dec.field, dec.path, "could not parse number: %s", err)
+			return dec.newError(code, "could not parse number: %w", err)
 		}
 		dec.curr.SetUint(num)
 	case reflect.Float32, reflect.Float64:
-		num, err := strconv.ParseFloat(dec.currValues[0], dec.curr.Type().Bits())
+		v, err := dec.number(dec.currValues[0])
+		if err != nil {
+			return err
+		}
+		num, err := strconv.ParseFloat(v, dec.curr.Type().Bits())
 		if err != nil {
 			code := ErrCodeConversion
 			if err, ok := err.(*strconv.NumError); ok && err.Err == strconv.ErrRange {
 				code = ErrCodeRange
 			}
-			return newError(code, dec.field, dec.path, "could not parse float: %s", err)
+			return dec.newError(code, "could not parse float: %w", err)
 		}
 		dec.curr.SetFloat(num)
+	case reflect.Complex64, reflect.Complex128:
+		num, err := strconv.ParseComplex(dec.currValues[0], dec.curr.Type().Bits())
+		if err != nil {
+			code := ErrCodeConversion
+			if err, ok := err.(*strconv.NumError); ok && err.Err == strconv.ErrRange {
+				code = ErrCodeRange
+			}
+			return dec.newError(code, "could not parse complex: %w", err)
+		}
+		dec.curr.SetComplex(num)
 	case reflect.Bool:
 		switch dec.currValues[0] {
 		case "true", "on", "1", "checked":
@@ -507,8 +1002,18 @@ func (dec *Decoder) decode() error {
 			return nil
 		}
 	case reflect.Interface:
-		dec.curr.Set(reflect.ValueOf(dec.currValues[0]))
+		if dec.curr.NumMethod() > 0 {
+			if dec.opts.IgnoreUnknownKeys {
+				return nil
+			}
+			return dec.newError(ErrCodeUnknownType, "unsupported type")
+		}
+		dec.curr.Set(reflect.ValueOf(dec.interfaceValue(dec.currValues[0])))
 	case reflect.Ptr:
+		if dec.skip > 0 {
+			dec.curr = dec.curr.Elem()
+			return dec.decode()
+		}
 		n := reflect.New(dec.curr.Type().Elem())
 		if dec.curr.CanSet() {
 			dec.curr.Set(n)
@@ -532,11 +1037,11 @@ func (dec *Decoder) decode() error {
 					return nil
 				}
 			}
-			return newError(ErrCodeConversion, dec.field, dec.path, "could not parse field: no suitable time formats")
+			return dec.newError(ErrCodeConversion, "could not parse field: no suitable time formats")
 		case url.URL:
 			u, err := url.Parse(dec.currValues[0])
 			if err != nil {
-				return newError(ErrCodeConversion, dec.field, dec.path, "could not parse field: %s", err)
+				return dec.newError(ErrCodeConversion, "could not parse field: %w", err)
 			}
 			dec.curr.Set(reflect.ValueOf(*u))
 		default:
@@ -552,23 +1057,82 @@ func (dec *Decoder) decode() error {
 					return nil
 				}
 			}
-			return newError(ErrCodeUnknownType, dec.field, dec.path,
-				"unsupported type; maybe include it the UnmarshalText interface or register it using custom type?")
+			return dec.newError(ErrCodeUnknownType, "unsupported type; maybe include it the UnmarshalText interface or register it using custom type?")
 		}
 	default:
 		if dec.opts.IgnoreUnknownKeys {
 			return nil
 		}
-		return newError(ErrCodeUnknownType, dec.field, dec.path, "unsupported type")
+		return dec.newError(ErrCodeUnknownType, "unsupported type")
 	}
 
 	return nil
 }
 
-// findStructField finds a field by its name, if it is not found,
-// then retry the search examining the tag "formam" of every field of struct
-func (dec *Decoder) findStructField() error {
+// decodeBytes sets the first value in the []byte field, decoded with the
+// encoding of the "encoding" tag option: raw, base64, base64url or hex. The
+// padding of base64 is optional.
+func (dec *Decoder) decodeBytes(enc string) error {
+	v := dec.currValues[0]
+	var b []byte
+	var err error
+	switch enc {
+	case "raw":
+		b = []byte(v)
+	case "base64":
+		b, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(v, "="))
+	case "base64url":
+		b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(v, "="))
+	case "hex":
+		b, err = hex.DecodeString(v)
+	default:
+		return dec.newError(ErrCodeUnknownType, "unknown encoding %q", enc)
+	}
+	if err != nil {
+		return dec.newError(ErrCodeConversion, "could not decode %s: %w", enc, err)
+	}
+	dec.curr.SetBytes(b)
+	return nil
+}
+
+// findStructField finds the field of the current segment, the last one if
+// last is true, and checks that it is allowed by Groups, Allow and Deny.
+func (dec *Decoder) findStructField(last bool) error {
+	n := len(dec.names)
+	if err := dec.lookupStructField(); err != nil {
+		dec.names = dec.names[:n]
+		return err
+	}
+	// the names of the embedded structs the field is promoted from
+	embedded := dec.names[n:]
+	dec.names = dec.names[:n]
+	for _, e := range embedded {
+		if !dec.inGroups(e.fieldTag) {
+			return dec.notAllowed()
+		}
+	}
+	if !dec.inGroups(dec.structField.Tag) {
+		return dec.notAllowed()
+	}
+	name := dec.fieldName(dec.structField)
+	if err := dec.allowed(last, append(embedded, name)...); err != nil {
+		return err
+	}
+	dec.names = append(append(dec.names, embedded...), name)
+	return nil
+}
+
+// lookupStructField finds a field by its name, if it is not found,
+// then retry the search examining the tag "formam" of every field of struct.
+// The embedded structs the field is promoted from are appended to
+// Decoder.names.
+func (dec *Decoder) lookupStructField() error {
 	var anon reflect.Value
+	var anonField reflect.StructField
+	var anonType reflect.Type
+	var anonNames []pathName
+	skip := false // the field is skipped in an anonymous struct
+	n := len(dec.names)
 
 	num := dec.curr.NumField()
 	for i := 0; i < num; i++ {
@@ -578,10 +1142,13 @@ func (dec *Decoder) findStructField() error {
 			tag := field.Tag.Get(dec.opts.TagName)
 			if tag == "-" {
 				// skip this field
-				return nil
+				return errSkip
 			}
 			// check if the field's name is equal
+			dec.structType = dec.curr.Type()
 			dec.curr = dec.curr.Field(i)
+			dec.structField = field
+			dec.names = dec.names[:n]
 			return nil
 		} else if field.Anonymous {
 			// if the field is a anonymous struct, then iterate over its fields
@@ -598,9 +1165,15 @@ func (dec *Decoder) findStructField() error {
 			// Otherwise we don't know the field is missing.
 			tmpIgnoreUnknownKeys := dec.opts.IgnoreUnknownKeys
 			dec.opts.IgnoreUnknownKeys = false
-			err := dec.findStructField()
+			dec.names = append(dec.names[:n], dec.fieldName(field))
+			err := dec.lookupStructField()
 			dec.opts.IgnoreUnknownKeys = tmpIgnoreUnknownKeys
 
+			if err == errSkip {
+				dec.curr = tmp
+				skip = true
+				continue
+			}
 			if err != nil {
 				dec.curr = tmp
 				continue
@@ -609,23 +1182,33 @@ func (dec *Decoder) findStructField() error {
 			// but first it should found the field in the rest of struct
 			// (a field with same name in the current struct should have preference over anonymous struct)
 			anon = dec.curr
+			anonField = dec.structField
+			anonType = dec.structType
+			anonNames = append([]pathName(nil), dec.names[n:]...)
 			dec.curr = tmp
 		} else if dec.field == getTagName(field.Tag, dec.opts.TagName) {
 			// is not found yet, then retry by its tag name "formam"
+			dec.structType = dec.curr.Type()
 			dec.curr = dec.curr.Field(i)
+			dec.structField = field
+			dec.names = dec.names[:n]
 			return nil
 		}
 	}
 
+	dec.names = dec.names[:n]
 	if anon.IsValid() {
 		dec.curr = anon
+		dec.structField = anonField
+		dec.structType = anonType
+		dec.names = append(dec.names, anonNames...)
 		return nil
 	}
-	if dec.opts.IgnoreUnknownKeys {
-		return nil
+	if skip || dec.opts.IgnoreUnknownKeys {
+		return errSkip
 	}
 
-	return newError(ErrCodeUnknownField, dec.field, dec.path, "unknown field")
+	return dec.newError(ErrCodeUnknownField, "unknown field")
 }
 
 // expandSlice expands the length and capacity of the current slice.
@@ -641,18 +1224,94 @@ func (dec *Decoder) expandSlice(length int) error {
 		return errors.New("array size " + strconv.Itoa(length) + " is longer than MaxSize " + strconv.Itoa(dec.opts.MaxSize))
 	}
 
-	n := reflect.MakeSlice(dec.curr.Type(), length, length)
+	// reuse the capacity of the slice, zeroing the elements added to it
+	if length <= dec.curr.Cap() {
+		dec.curr.SetLen(length)
+		zero := reflect.Zero(dec.curr.Type().Elem())
+		for i := currLen; i < length; i++ {
+			dec.curr.Index(i).Set(zero)
+		}
+		return nil
+	}
+
+	// grow the capacity like append, so that the indexes in order don't
+	// allocate a new slice every time
+	capacity := 2 * dec.curr.Cap()
+	if capacity < length || (dec.opts.MaxSize >= 0 && capacity > dec.opts.MaxSize) {
+		capacity = length
+	}
+	n := reflect.MakeSlice(dec.curr.Type(), length, capacity)
 	reflect.Copy(n, dec.curr)
 	dec.curr.Set(n)
 	return nil
 }
 
+// arrayLength checks the number of values for the current array with the
+// ArrayLength option, truncating them if needed.
+func (dec *Decoder) arrayLength() error {
+	// the values of a repeated key read by DecodeReader go after the ones
+	// decoded before
+	n, length := dec.skip+len(dec.currValues), dec.curr.Len()
+	switch dec.opts.ArrayLength {
+	case ArrayLengthTruncate:
+		if n > length {
+			keep := length - dec.skip
+			if keep < 0 {
+				keep = 0
+			}
+			dec.currValues = dec.currValues[:keep]
+		}
+	case ArrayLengthExact:
+		if n < length && dec.streaming {
+			// the key can be repeated later
+			if dec.short == nil {
+				dec.short = make(map[string]error)
+			}
+			dec.short[FormatPath(dec.segs)] = dec.newError(ErrCodeArrayLength, "%d values for an array of length %d", n, length)
+			return nil
+		}
+		delete(dec.short, FormatPath(dec.segs))
+		if n != length {
+			return dec.newError(ErrCodeArrayLength, "%d values for an array of length %d", n, length)
+		}
+	default:
+		if n > length {
+			return dec.newError(ErrCodeArrayLength, "%d values for an array of length %d", n, length)
+		}
+	}
+	return nil
+}
+
+// shortArrays returns the errors of the arrays that DecodeReader didn't read
+// enough values for, with the ArrayLengthExact option. They are added to
+// dec.errs with the CollectErrors option.
+func (dec *Decoder) shortArrays() error {
+	paths := make([]string, 0, len(dec.short))
+	for path := range dec.short {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := dec.collect(&dec.errs, dec.short[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // setValues set the values in current slice/array
 func (dec *Decoder) setValues() error {
 	tmp := dec.curr // hold current field
-	for i, v := range dec.currValues {
-		dec.curr = tmp.Index(i)
-		dec.currValues[0] = v
+	values, offset := dec.currValues, dec.offset+dec.skip
+	dec.offset, dec.skip = 0, 0
+	n, m := len(dec.segs), len(dec.names)
+	for i := range values {
+		dec.curr = tmp.Index(offset + i)
+		dec.currValues = values[i : i+1]
+		dec.segs = append(dec.segs[:n], PathSegment{Kind: IndexSegment, Name: strconv.Itoa(offset + i)})
+		dec.steps = append(dec.steps[:n], valueStep{index: offset + i})
+		dec.names = append(dec.names[:m], pathName{name: dec.segs[n].Name})
+		dec.touch()
 		if err := dec.decode(); err != nil {
 			return err
 		}
@@ -662,42 +1321,183 @@ func (dec *Decoder) setValues() error {
 
 // isCustomType checks if the field's type to decode has a custom type registered
 func (dec *Decoder) isCustomType() (bool, error) {
-	if dec.customTypes == nil {
+	fn := dec.customTypeFunc()
+	if fn == nil {
 		return false, nil
 	}
+	return true, dec.setCustomType(fn(dec.currValues))
+}
+
+// customTypeFunc returns the function of the custom type registered for the
+// current field, or nil.
+func (dec *Decoder) customTypeFunc() DecodeCustomTypeFunc {
+	if dec.customTypes == nil {
+		return nil
+	}
 	if v, ok := dec.customTypes[dec.curr.Type()]; ok {
 		if len(v.fields) > 0 {
 			for i := range v.fields {
 				// check if the current field is registered
 				// in the fields of the custom type
-				if v.fields[i].field.Elem() == dec.curr {
-					va, err := v.fields[i].fn(dec.currValues)
-					if err != nil {
-						return true, err
-					}
-					dec.curr.Set(reflect.ValueOf(va))
-					return true, nil
+				if dec.isCustomTypeField(v.fields[i]) {
+					return v.fields[i].fn
 				}
 			}
 		}
 		// check if the default function exists for fields not specific
-		if v.fn != nil {
-			va, err := v.fn(dec.currValues)
-			if err != nil {
-				return true, err
+		if v.ctxFn != nil {
+			ctx := dec.fieldContext()
+			return func(vals []string) (interface{}, error) {
+				return v.ctxFn(ctx, vals)
 			}
-			dec.curr.Set(reflect.ValueOf(va))
-			return true, nil
 		}
+		if v.fn != nil {
+			return v.fn
+		}
+	}
+	return nil
+}
+
+// repeats reports if the current value gets the values of a key repeated
+// after the ones decoded before, which DecodeReader reads later: the
+// elements of slices and arrays, and UnmarshalText for a key ending in [].
+// The other values keep the first value of the key, like with Decode.
+func (dec *Decoder) repeats() bool {
+	typ := dec.curr.Type()
+	custom := dec.customTypeFunc() != nil
+	if !dec.opts.DisableUnmarshalText && isTextUnmarshaler(typ) && (dec.opts.PrefUnmarshalText || !custom) {
+		return strings.HasSuffix(dec.path, "[]")
+	}
+	if custom {
+		return false
+	}
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return !dec.curr.IsNil()
+	case reflect.Array:
+		return true
+	case reflect.Slice:
+		if dec.index == "" && typ.Elem().Kind() == reflect.Uint8 {
+			_, enc := tagOption(dec.structField.Tag, dec.opts.TagName, "encoding")
+			return !enc
+		}
+		return true
+	}
+	return false
+}
+
+// isTextUnmarshaler reports if the values of typ are decoded by
+// isUnmarshalText.
+func isTextUnmarshaler(typ reflect.Type) bool {
+	if typ.ConvertibleTo(typeTime) || typ.ConvertibleTo(typeTimePtr) {
+		return false
 	}
-	return false, nil
+	return implements(typ, typeTextUnmarshaler) || implements(reflect.PtrTo(typ), typeTextUnmarshaler)
+}
+
+// setCustomType sets the value returned by a custom type in the current
+// field.
+func (dec *Decoder) setCustomType(v interface{}, err error) error {
+	if err != nil {
+		return dec.newError(ErrCodeConversion, "could not decode field: %w", err)
+	}
+	val := reflect.ValueOf(v)
+	if !val.IsValid() {
+		val = reflect.Zero(dec.curr.Type())
+	}
+	if !val.Type().AssignableTo(dec.curr.Type()) {
+		return dec.newError(ErrCodeConversion, "custom type returned a %v instead of a %v", val.Type(), dec.curr.Type())
+	}
+	dec.curr.Set(val)
+	return nil
 }
 
 var (
-	typeTime    = reflect.TypeOf(time.Time{})
-	typeTimePtr = reflect.TypeOf(&time.Time{})
+	typeTime           = reflect.TypeOf(time.Time{})
+	typeTimePtr        = reflect.TypeOf(&time.Time{})
+	typeURL            = reflect.TypeOf(url.URL{})
+	typeInterfaceMap   = reflect.TypeOf(map[string]interface{}{})
+	typeInterfaceSlice = reflect.TypeOf([]interface{}{})
 )
 
+// isCustomTypeField reports whether f is registered for the current field.
+func (dec *Decoder) isCustomTypeField(f *decodeCustomTypeField) bool {
+	switch {
+	case f.pattern != nil:
+		return matchPath(f.pattern, dec.names)
+	case f.structField != nil:
+		return dec.structType == f.structType && dec.structField.Name == f.structField.Name
+	}
+	return f.field.Elem() == dec.curr
+}
+
+// matchPath reports whether path matches the pattern, where * matches any
+// name and the embedded structs can be omitted, like matchPathPrefix.
+func matchPath(pattern []PathSegment, path []pathName) bool {
+	if len(path) == 0 {
+		return len(pattern) == 0
+	}
+	if path[0].embedded && matchPath(pattern, path[1:]) {
+		return true
+	}
+	return len(pattern) > 0 && path[0].is(pattern[0].Name) && matchPath(pattern[1:], path[1:])
+}
+
+// interfaceValue returns the value to set in an interface{} for the value v:
+// a bool, an int64 or a float64 if SniffInterfaceValues is set and v looks
+// like one, or v itself.
+func (dec *Decoder) interfaceValue(v string) interface{} {
+	if !dec.opts.SniffInterfaceValues {
+		return v
+	}
+	switch v {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	// a number is only sniffed if it's written as it's formatted, so
+	// values such as zip codes with a leading zero, +5 or 1.50 are kept as
+	// they are, and so are NaN and the infinities
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil && strconv.FormatInt(n, 10) == v {
+		return n
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) && strconv.FormatFloat(f, 'f', -1, 64) == v {
+		return f
+	}
+	return v
+}
+
+// implementations caches if the types or their pointers implement the
+// interfaces.
+var implementations sync.Map // map[implementation]bool
+
+type implementation struct {
+	typ, iface reflect.Type
+}
+
+// implements reports if typ or a pointer to it implements one of the
+// interfaces.
+func implements(typ reflect.Type, ifaces ...reflect.Type) bool {
+	// predeclared and unnamed types don't have methods, except unnamed
+	// structs with embedded fields
+	if typ.PkgPath() == "" && typ.Kind() != reflect.Struct {
+		return false
+	}
+	for _, iface := range ifaces {
+		key := implementation{typ, iface}
+		ok, found := implementations.Load(key)
+		if !found {
+			ok = typ.Implements(iface) || reflect.PtrTo(typ).Implements(iface)
+			implementations.Store(key, ok)
+		}
+		if ok.(bool) {
+			return true
+		}
+	}
+	return false
+}
+
 // isUnmarshalText returns a boolean and error. The boolean is true if the
 // field's type implements TextUnmarshaler, and false if not.
 // If the field implements TextUnmarshaler, then it is used to decode the value
@@ -722,13 +1522,16 @@ func (dec *Decoder) isUnmarshalText(v reflect.Value) (bool, error) {
 		for _, v := range dec.currValues {
 			err := m.UnmarshalText([]byte(v))
 			if err != nil {
-				return true, err
+				return true, dec.newError(ErrCodeConversion, "could not decode field: %w", err)
 			}
 		}
 		return true, nil
 	}
 
-	return true, m.UnmarshalText([]byte(dec.currValues[0]))
+	if err := m.UnmarshalText([]byte(dec.currValues[0])); err != nil {
+		return true, dec.newError(ErrCodeConversion, "could not decode field: %w", err)
+	}
+	return true, nil
 }
 
 // getTagName get tag by the name passed by argument
@@ -739,3 +1542,35 @@ func getTagName(t reflect.StructTag, tagName string) string {
 	}
 	return tag
 }
+
+// tagOptions returns the options after the name in the tag tagName, such as
+// "trim,lower" in `formam:"email,trim,lower"`, and whether there are any.
+func tagOptions(t reflect.StructTag, tagName string) (string, bool) {
+	tag := t.Get(tagName)
+	if p := strings.IndexByte(tag, ','); p != -1 {
+		return tag[p+1:], true
+	}
+	return "", false
+}
+
+// nextTagOption returns the first option in opts and the rest.
+func nextTagOption(opts string) (string, string) {
+	if p := strings.IndexByte(opts, ','); p != -1 {
+		return opts[:p], opts[p+1:]
+	}
+	return opts, ""
+}
+
+// tagOption returns the value of the option key in the tag tagName, such as
+// "de" for the key "number" in `formam:"price,number=de"`.
+func tagOption(t reflect.StructTag, tagName, key string) (string, bool) {
+	opts, ok := tagOptions(t, tagName)
+	for ok && opts != "" {
+		var opt string
+		opt, opts = nextTagOption(opts)
+		if strings.HasPrefix(opt, key) && len(opt) > len(key) && opt[len(key)] == '=' {
+			return opt[len(key)+1:], true
+		}
+	}
+	return "", false
+}
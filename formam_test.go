@@ -2,8 +2,12 @@ package formam_test
 
 import (
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
+	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
@@ -1234,3 +1238,573 @@ func TestTimeFormats(t *testing.T) {
 		}
 	})
 }
+
+func TestDecodeOrder(t *testing.T) {
+	s := struct {
+		A int
+		B int
+		C []FieldString
+	}{}
+	vals := url.Values{
+		"C[1]": []string{"c1"},
+		"B":    []string{"not a number"},
+		"C[0]": []string{"c0"},
+		"A":    []string{"not a number either"},
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			err := formam.NewDecoder(nil).Decode(vals, &s)
+			if err == nil {
+				t.Fatal("error is nil")
+			}
+			if p := err.(*formam.Error).Path(); p != "A" {
+				t.Fatalf("wrong path in error: %q", p)
+			}
+		}
+	})
+
+	t.Run("side effects", func(t *testing.T) {
+		var calls []string
+		dec := formam.NewDecoder(&formam.DecoderOptions{IgnoreUnknownKeys: true})
+		dec.RegisterCustomType(func(vals []string) (interface{}, error) {
+			calls = append(calls, vals[0])
+			return FieldString(vals[0]), nil
+		}, []interface{}{FieldString("")}, nil)
+
+		for i := 0; i < 20; i++ {
+			calls = nil
+			dec.Decode(url.Values{"C[1]": vals["C[1]"], "C[0]": vals["C[0]"]}, &s)
+			if strings.Join(calls, ",") != "c0,c1" {
+				t.Fatalf("wrong order: %v", calls)
+			}
+		}
+	})
+}
+
+func TestBracketFields(t *testing.T) {
+	type Address struct {
+		City string `formam:"city"`
+	}
+	s := struct {
+		User struct {
+			Name    string
+			Address Address `formam:"address"`
+			Phones  []string
+		} `formam:"user"`
+	}{}
+
+	vals := url.Values{
+		"user[Name]":            []string{"Homer"},
+		"user[address][city]":   []string{"Springfield"},
+		"user[Phones][1]":       []string{"555-333-222"},
+		"user.address[city]":    []string{"Springfield"},
+		"user[address].city":    []string{"Springfield"},
+		"[user][address][city]": []string{"Springfield"},
+	}
+
+	err := formam.NewDecoder(nil).Decode(vals, &s)
+	if !errorContains(err, "has an array index but it is a struct") {
+		t.Fatalf("wrong error: %s", err)
+	}
+
+	err = formam.NewDecoder(&formam.DecoderOptions{BracketFields: true}).Decode(vals, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.User.Name != "Homer" {
+		t.Errorf("Name is %q", s.User.Name)
+	}
+	if s.User.Address.City != "Springfield" {
+		t.Errorf("City is %q", s.User.Address.City)
+	}
+	if len(s.User.Phones) != 2 || s.User.Phones[1] != "555-333-222" {
+		t.Errorf("Phones is %q", s.User.Phones)
+	}
+
+	err = formam.NewDecoder(&formam.DecoderOptions{BracketFields: true}).Decode(url.Values{
+		"user[Unknown]": []string{"x"},
+	}, &s)
+	if !errorContains(err, "unknown field") {
+		t.Fatalf("wrong error: %s", err)
+	}
+}
+
+func TestGenericInterfaces(t *testing.T) {
+	type S struct {
+		Extra  interface{}
+		Values interface{}
+		Iface  interface{}
+	}
+
+	vals := url.Values{
+		"Extra.a[0].b":   []string{"1"},
+		"Extra.a[0].c":   []string{"x"},
+		"Extra.a[1].b":   []string{"2"},
+		"Extra[d][e]":    []string{"true"},
+		"Extra.f":        []string{"1.5"},
+		"Extra.g":        []string{"NaN"},
+		"Values[]":       []string{"a", "b"},
+		"Iface.Name":     []string{"Homer"},
+		"Iface.Children": []string{"Bart", "Lisa"},
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		var s S
+		err := formam.NewDecoder(nil).Decode(url.Values{"Extra.a": []string{"1"}}, &s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Extra != "1" {
+			t.Errorf("Extra is %#v", s.Extra)
+		}
+	})
+
+	t.Run("strings", func(t *testing.T) {
+		s := S{Iface: &struct {
+			Name     string
+			Children []string
+		}{}}
+		err := formam.NewDecoder(&formam.DecoderOptions{GenericInterfaces: true}).Decode(vals, &s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := fmt.Sprintf("%v", s.Extra)
+		want := "map[a:[map[b:1 c:x] map[b:2]] d:map[e:true] f:1.5 g:NaN]"
+		if out != want {
+			t.Errorf("\nout:  %s\nwant: %s", out, want)
+		}
+		if out := fmt.Sprintf("%#v", s.Values); out != `[]interface {}{"a", "b"}` {
+			t.Errorf("Values is %s", out)
+		}
+		if out := fmt.Sprintf("%v", s.Iface); out != "&{Homer [Bart Lisa]}" {
+			t.Errorf("Iface is %s", out)
+		}
+	})
+
+	t.Run("sniff", func(t *testing.T) {
+		var s S
+		err := formam.NewDecoder(&formam.DecoderOptions{
+			GenericInterfaces:    true,
+			SniffInterfaceValues: true,
+		}).Decode(vals, &s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := fmt.Sprintf("%#v", s.Extra)
+		want := `map[string]interface {}{"a":[]interface {}{map[string]interface {}{"b":1, "c":"x"}, map[string]interface {}{"b":2}}, "d":map[string]interface {}{"e":true}, "f":1.5, "g":"NaN"}`
+		if out != want {
+			t.Errorf("\nout:  %s\nwant: %s", out, want)
+		}
+		m := s.Iface.(map[string]interface{})
+		if out := fmt.Sprintf("%#v", m["Children"]); out != `"Bart"` {
+			t.Errorf("Children is %s", out)
+		}
+	})
+
+	t.Run("sniff keeps the text", func(t *testing.T) {
+		var m map[string]interface{}
+		err := formam.NewDecoder(&formam.DecoderOptions{SniffInterfaceValues: true}).Decode(url.Values{
+			"zip":   {"01234"},
+			"phone": {"+34600123456"},
+			"price": {"1.50"},
+			"exp":   {"1e3"},
+			"inf":   {"+Inf"},
+			"big":   {"1234567.5"},
+			"neg":   {"-12"},
+		}, &m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := fmt.Sprintf("%#v", m)
+		want := `map[string]interface {}{"big":1.2345675e+06, "exp":"1e3", "inf":"+Inf", "neg":-12, "phone":"+34600123456", "price":"1.50", "zip":"01234"}`
+		if out != want {
+			t.Errorf("\nout:  %s\nwant: %s", out, want)
+		}
+	})
+}
+
+func TestDecodeInDynamicMap(t *testing.T) {
+	vals := url.Values{
+		"user[name]":    []string{"Homer"},
+		"user.tags[]":   []string{"dad", "safety"},
+		"items[0][id]":  []string{"1"},
+		"items[1][id]":  []string{"2"},
+		"items[1][qty]": []string{"3"},
+	}
+
+	t.Run("map[string]interface{}", func(t *testing.T) {
+		var m map[string]interface{}
+		if err := formam.Decode(vals, &m); err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"items":[{"id":"1"},{"id":"2","qty":"3"}],"user":{"name":"Homer","tags":["dad","safety"]}}`
+		if string(out) != want {
+			t.Errorf("\nout:  %s\nwant: %s", out, want)
+		}
+	})
+
+	t.Run("interface{}", func(t *testing.T) {
+		var m interface{}
+		dec := formam.NewDecoder(&formam.DecoderOptions{SniffInterfaceValues: true})
+		if err := dec.Decode(url.Values{"[0].id": {"1"}, "[1].id": {"2"}}, &m); err != nil {
+			t.Fatal(err)
+		}
+		out, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `[{"id":1},{"id":2}]`; string(out) != want {
+			t.Errorf("\nout:  %s\nwant: %s", out, want)
+		}
+	})
+
+	t.Run("url.Values", func(t *testing.T) {
+		m := url.Values{}
+		if err := formam.Decode(vals, &m); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(m, vals) {
+			t.Errorf("\nout:  %v\nwant: %v", m, vals)
+		}
+
+		var m2 map[string]string
+		if err := formam.Decode(vals, &m2); err != nil {
+			t.Fatal(err)
+		}
+		if m2["user.tags[]"] != "dad" || len(m2) != len(vals) {
+			t.Errorf("wrong map: %v", m2)
+		}
+	})
+}
+
+func TestComplex(t *testing.T) {
+	s := struct {
+		C64  complex64
+		C128 complex128
+		Cs   []complex128
+	}{}
+	err := formam.Decode(url.Values{
+		"C64":  []string{"1+2i"},
+		"C128": []string{"(-1.5-0.5i)"},
+		"Cs[]": []string{"3", "4i"},
+	}, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.C64 != 1+2i || s.C128 != -1.5-0.5i || len(s.Cs) != 2 || s.Cs[0] != 3 || s.Cs[1] != 4i {
+		t.Errorf("%+v", s)
+	}
+
+	err = formam.Decode(url.Values{"C64": []string{"1+i2"}}, &s)
+	if err == nil || err.(*formam.Error).Code() != formam.ErrCodeConversion {
+		t.Errorf("wrong error: %v", err)
+	}
+	err = formam.Decode(url.Values{"C64": []string{"1e100"}}, &s)
+	if err == nil || err.(*formam.Error).Code() != formam.ErrCodeRange {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestBytesEncoding(t *testing.T) {
+	type bytes struct {
+		Numbers []byte
+		Raw     []byte   `formam:"raw,encoding=raw"`
+		Base64  []byte   `formam:"b64,encoding=base64"`
+		URL     []byte   `formam:"url,encoding=base64url"`
+		Hex     []byte   `formam:"hex,encoding=hex"`
+		Tokens  [][]byte `formam:"tokens,encoding=hex"`
+		Unknown []byte   `formam:"unknown,encoding=x"`
+	}
+
+	s := bytes{}
+	err := formam.Decode(url.Values{
+		"Numbers":  []string{"104", "105"},
+		"raw":      []string{"hi there"},
+		"b64":      []string{"aGVsbG8/Pw=="},
+		"url":      []string{"aGVsbG8_Pw"},
+		"hex":      []string{"cafe"},
+		"tokens[]": []string{"00", "ff01"},
+	}, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes{
+		Numbers: []byte("hi"),
+		Raw:     []byte("hi there"),
+		Base64:  []byte("hello??"),
+		URL:     []byte("hello??"),
+		Hex:     []byte{0xca, 0xfe},
+		Tokens:  [][]byte{{0}, {0xff, 1}},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("\nhave: %q\nwant: %q", s, want)
+	}
+
+	for _, tt := range []struct {
+		key, value string
+		code       formam.ErrorCode
+	}{
+		{"b64", "aGVsbG8_Pw", formam.ErrCodeConversion},
+		{"url", "aGVsbG8/Pw", formam.ErrCodeConversion},
+		{"hex", "xyz", formam.ErrCodeConversion},
+		{"unknown", "a", formam.ErrCodeUnknownType},
+	} {
+		err := formam.Decode(url.Values{tt.key: []string{tt.value}}, &bytes{})
+		if err == nil || err.(*formam.Error).Code() != tt.code {
+			t.Errorf("%s=%s: wrong error: %v", tt.key, tt.value, err)
+		}
+	}
+}
+
+func TestCustomTypePath(t *testing.T) {
+	type Event struct {
+		Start time.Time
+		End   time.Time
+		Dates []time.Time
+	}
+	type Calendar struct {
+		Events  []Event
+		Main    Event
+		Holiday map[string]time.Time
+	}
+
+	parse := func(layout string) formam.DecodeCustomTypeFunc {
+		return func(vals []string) (interface{}, error) {
+			return time.Parse(layout, vals[0])
+		}
+	}
+	dates, _ := reflect.TypeOf(Event{}).FieldByName("Dates")
+	dec := formam.NewDecoder(nil).
+		RegisterCustomTypePath(parse("02/01/2006"), []interface{}{time.Time{}}, []string{"Events[*].Start", "Holiday[*]"}).
+		RegisterCustomTypePath(parse("2006"), []interface{}{time.Time{}}, []string{"Main.*"}).
+		RegisterCustomTypeStructField(parse("Jan 2006"), []interface{}{time.Time{}}, Event{}, []reflect.StructField{dates})
+
+	// a new destination for every decoding, as with a shared decoder
+	for i := 0; i < 2; i++ {
+		var c Calendar
+		err := dec.Decode(url.Values{
+			"Events[0].Start":   []string{"25/12/2020"},
+			"Events[0].End":     []string{"2020-12-26"},
+			"Events[1].Start":   []string{"31/12/2020"},
+			"Events[1].Dates[]": []string{"Jan 2021", "Feb 2021"},
+			"Main.Start":        []string{"2019"},
+			"Main.End":          []string{"2020"},
+			"Main.Dates[0]":     []string{"Mar 2021"},
+			"Holiday[xmas]":     []string{"25/12/2021"},
+		}, &c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range []struct {
+			have time.Time
+			want string
+		}{
+			{c.Events[0].Start, "2020-12-25"},
+			{c.Events[0].End, "2020-12-26"},
+			{c.Events[1].Start, "2020-12-31"},
+			{c.Events[1].Dates[0], "2021-01-01"},
+			{c.Events[1].Dates[1], "2021-02-01"},
+			{c.Main.Start, "2019-01-01"},
+			{c.Main.End, "2020-01-01"},
+			{c.Main.Dates[0], "2021-03-01"},
+			{c.Holiday["xmas"], "2021-12-25"},
+		} {
+			if have := tt.have.Format("2006-01-02"); have != tt.want {
+				t.Errorf("have %s, want %s", have, tt.want)
+			}
+		}
+	}
+
+	// the other paths use the default decoding
+	var c Calendar
+	err := dec.Decode(url.Values{"Events[0].End": []string{"25/12/2020"}}, &c)
+	if err == nil || err.(*formam.Error).Code() != formam.ErrCodeConversion {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	// a struct field only matches in its struct, and the ones embedding it
+	type Meeting struct {
+		Start time.Time
+		End   time.Time
+		Dates []time.Time
+	}
+	type Party struct {
+		Event
+	}
+	var m Meeting
+	err = dec.Decode(url.Values{"Dates[0]": []string{"Jan 2021"}}, &m)
+	if err == nil || err.(*formam.Error).Code() != formam.ErrCodeConversion {
+		t.Errorf("wrong error: %v", err)
+	}
+	var p Party
+	err = dec.Decode(url.Values{"Dates[0]": []string{"Jan 2021"}}, &p)
+	if err != nil || p.Dates[0].Format("2006-01") != "2021-01" {
+		t.Errorf("%v: %v", err, p.Dates)
+	}
+
+	// the field is looked up in its struct
+	promoted, _ := reflect.TypeOf(Party{}).FieldByName("Dates")
+	formam.NewDecoder(nil).RegisterCustomTypeStructField(parse("Jan 2006"), []interface{}{time.Time{}}, Party{}, []reflect.StructField{promoted})
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for a field of another struct")
+		}
+	}()
+	formam.NewDecoder(nil).RegisterCustomTypeStructField(parse("Jan 2006"), []interface{}{time.Time{}}, Meeting{}, []reflect.StructField{promoted})
+}
+
+func TestCustomTypePathAndAll(t *testing.T) {
+	type Event struct {
+		Start time.Time
+		End   time.Time
+	}
+	parse := func(layout string) formam.DecodeCustomTypeFunc {
+		return func(vals []string) (interface{}, error) {
+			return time.Parse(layout, vals[0])
+		}
+	}
+	path := func(dec *formam.Decoder) *formam.Decoder {
+		return dec.RegisterCustomTypePath(parse("02/01/2006"), []interface{}{time.Time{}}, []string{"Events[*].Start"})
+	}
+	all := func(dec *formam.Decoder) *formam.Decoder {
+		return dec.RegisterCustomType(parse("2006"), []interface{}{time.Time{}}, nil)
+	}
+
+	for name, dec := range map[string]*formam.Decoder{
+		"path first": all(path(formam.NewDecoder(nil))),
+		"all first":  path(all(formam.NewDecoder(nil))),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var s struct{ Events []Event }
+			err := dec.Decode(url.Values{"Events[0].Start": {"25/12/2020"}, "Events[0].End": {"2021"}}, &s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have := s.Events[0].Start.Format("2006-01-02"); have != "2020-12-25" {
+				t.Errorf("Start is %s", have)
+			}
+			if have := s.Events[0].End.Format("2006-01-02"); have != "2021-01-01" {
+				t.Errorf("End is %s", have)
+			}
+		})
+	}
+}
+
+func TestCustomTypePathNames(t *testing.T) {
+	type Event struct {
+		Start time.Time `formam:"start"`
+	}
+	type Base struct {
+		Events []Event `formam:"events"`
+	}
+	parse := func(vals []string) (interface{}, error) {
+		return time.Parse("02/01/2006", vals[0])
+	}
+	for _, pattern := range []string{"Events[*].Start", "events[*].start", "Base.Events[*].start"} {
+		dec := formam.NewDecoder(nil).RegisterCustomTypePath(parse, []interface{}{time.Time{}}, []string{pattern})
+		for _, key := range []string{"Events[0].Start", "events[0].start", "Base.events[0].Start"} {
+			var s struct{ Base }
+			if err := dec.Decode(url.Values{key: {"25/12/2020"}}, &s); err != nil {
+				t.Errorf("%s with %s: %s", key, pattern, err)
+			} else if have := s.Events[0].Start.Format("2006-01-02"); have != "2020-12-25" {
+				t.Errorf("%s with %s: Start is %s", key, pattern, have)
+			}
+		}
+	}
+}
+
+func TestArrayLengthPolicy(t *testing.T) {
+	type arrays struct {
+		A [2]int
+		B [2][2]string
+	}
+	tests := []struct {
+		policy formam.ArrayLengthPolicy
+		vals   url.Values
+		want   arrays
+		code   formam.ErrorCode
+		err    bool
+	}{
+		{formam.ArrayLengthError, url.Values{"A": {"1"}}, arrays{A: [2]int{1, 0}}, 0, false},
+		{formam.ArrayLengthError, url.Values{"A": {"1", "2", "3"}}, arrays{}, formam.ErrCodeArrayLength, true},
+		{formam.ArrayLengthError, url.Values{"B[1]": {"a", "b", "c"}}, arrays{}, formam.ErrCodeArrayLength, true},
+		{formam.ArrayLengthError, url.Values{"A[2]": {"1"}}, arrays{}, formam.ErrCodeArrayIndex, true},
+		{formam.ArrayLengthTruncate, url.Values{"A": {"1", "2", "3"}, "B[0]": {"a", "b", "c"}}, arrays{A: [2]int{1, 2}, B: [2][2]string{{"a", "b"}}}, 0, false},
+		{formam.ArrayLengthExact, url.Values{"A": {"1", "2"}}, arrays{A: [2]int{1, 2}}, 0, false},
+		{formam.ArrayLengthExact, url.Values{"A": {"1"}}, arrays{}, formam.ErrCodeArrayLength, true},
+		{formam.ArrayLengthExact, url.Values{"A": {"1", "2", "3"}}, arrays{}, formam.ErrCodeArrayLength, true},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var a arrays
+			err := formam.NewDecoder(&formam.DecoderOptions{ArrayLength: tt.policy}).Decode(tt.vals, &a)
+			if tt.err {
+				if e, ok := err.(*formam.Error); !ok || e.Code() != tt.code {
+					t.Errorf("wrong error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a != tt.want {
+				t.Errorf("\nhave: %v\nwant: %v", a, tt.want)
+			}
+		})
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	s := struct {
+		Items []struct {
+			Custom FieldString
+		}
+		Other string
+	}{}
+	dec := formam.NewDecoder(nil).RegisterCustomType(func(vals []string) (interface{}, error) {
+		if vals[0] == "wrong" {
+			return 5, nil
+		}
+		panic("custom type panicked")
+	}, []interface{}{FieldString("")}, nil)
+
+	err := dec.Decode(url.Values{"Items[1].Custom": {"x"}}, &s)
+	if e, ok := err.(*formam.Error); !ok || e.Code() != formam.ErrCodePanic || e.Path() != "Items[1].Custom" || !strings.Contains(e.Error(), "custom type panicked") {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	err = dec.Decode(url.Values{"Items[0].Custom": {"wrong"}}, &s)
+	if e, ok := err.(*formam.Error); !ok || e.Code() != formam.ErrCodeConversion {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	// the runtime errors are wrapped
+	var n *int
+	dec = formam.NewDecoder(nil).RegisterCustomType(func(vals []string) (interface{}, error) {
+		return FieldString(strconv.Itoa(*n)), nil
+	}, []interface{}{FieldString("")}, nil)
+	err = dec.Decode(url.Values{"Items[0].Custom": {"x"}}, &s)
+	var rErr runtime.Error
+	if !errors.As(err, &rErr) {
+		t.Errorf("not a runtime.Error: %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("no panic with DisablePanicRecovery")
+			}
+		}()
+		dec := formam.NewDecoder(&formam.DecoderOptions{DisablePanicRecovery: true})
+		dec.RegisterCustomType(func(vals []string) (interface{}, error) {
+			panic("custom type panicked")
+		}, []interface{}{FieldString("")}, nil)
+		dec.Decode(url.Values{"Items[0].Custom": {"x"}}, &s)
+	}()
+}
@@ -0,0 +1,143 @@
+//go:build go1.18
+// +build go1.18
+
+package formam_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/monoculum/formam/v3"
+)
+
+func FuzzDecodeArrays(f *testing.F) {
+	for _, seed := range []string{
+		"A=1&A=2&A=3",
+		"A[1]=1&A[5]=2",
+		"B[0]=a&B[0]=b&B[0]=c",
+		"B[1][1]=x&C[0][a]=1",
+		"D[]=1&D[]=2&D[]=3",
+		"A[-1]=1&B[x]=2",
+	} {
+		f.Add(seed)
+	}
+
+	policies := []formam.ArrayLengthPolicy{formam.ArrayLengthError, formam.ArrayLengthTruncate, formam.ArrayLengthExact}
+	f.Fuzz(func(t *testing.T, query string) {
+		vals, err := url.ParseQuery(query)
+		if err != nil {
+			return
+		}
+		for _, p := range policies {
+			var s struct {
+				A [2]int
+				B [2][2]string
+				C [1]map[string]int
+				D [3]*uint8
+			}
+			dec := formam.NewDecoder(&formam.DecoderOptions{ArrayLength: p, DisablePanicRecovery: true})
+			if err := dec.Decode(vals, &s); err != nil {
+				if _, ok := err.(*formam.Error); !ok {
+					t.Errorf("not an *Error: %v", err)
+				}
+			}
+		}
+	})
+}
+
+type fuzzInner struct {
+	S  string
+	I  int8
+	P  *uint16
+	T  time.Time
+	B  []byte `formam:"b,encoding=base64"`
+	Fs []float32
+}
+
+type fuzzStruct struct {
+	fuzzInner
+	Str     string `formam:"str,trim"`
+	Bool    bool
+	Int     int `formam:"int,base=0"`
+	Float   float64
+	Complex complex64
+	Slice   []fuzzInner
+	Array   [2]fuzzInner
+	Map     map[string]fuzzInner
+	IntMap  map[int]*fuzzInner
+	PtrMap  map[*UUID]string
+	Iface   interface{}
+	Nested  [][]map[string][]int
+	Text    UUID
+	Custom  FieldString
+	URL     url.URL
+	Skip    string `formam:"-"`
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{
+		"str=a&Bool=on&int=0x10&Float=1.5&Complex=1%2B2i",
+		"Slice[0].S=a&Slice[3].P=1&Array[1].Fs[]=1&Array[1].Fs[]=2",
+		"Map[a].T=2020-01-02&IntMap[5].I=1&PtrMap[11e5bf2d3e403a8c86740023dffe5350]=x",
+		"Iface.a[0].b=1&Iface[1]=2&Nested[0][1][k][2]=3",
+		"Slice[][S]=a&Slice[][S]=b&Map[a][S]=x",
+		"a]b[=1&[=2&]=3&.=4&a..b=5&a[[0]]=6&\"=7&a[\"b]=8",
+		"b=aGk=&Text=11e5bf2d3e403a8c86740023dffe5350&Custom=x&URL=http://x/%zz",
+		"Slice[-1].S=a&Slice[99999999999999999999].S=b&Array[2].S=c",
+		"S=a&I=300&P=-1&Skip=x",
+	} {
+		f.Add(seed)
+	}
+
+	newTargets := func() []interface{} {
+		iface := interface{}(&fuzzInner{})
+		return []interface{}{
+			&fuzzStruct{},
+			&[]fuzzStruct{},
+			&map[string]interface{}{},
+			&map[string]string{},
+			&url.Values{},
+			new(interface{}),
+			&iface,
+			&map[string][3]*fuzzInner{},
+		}
+	}
+	options := []formam.DecoderOptions{
+		{DisablePanicRecovery: true},
+		{DisablePanicRecovery: true, BracketFields: true, GenericInterfaces: true, SniffInterfaceValues: true},
+		{DisablePanicRecovery: true, PathParser: formam.BracketPathParser{AllowDots: true}, IgnoreUnknownKeys: true},
+		{DisablePanicRecovery: true, Collections: formam.AppendCollections, ArrayLength: formam.ArrayLengthTruncate, CollectErrors: true},
+		{DisablePanicRecovery: true, Collections: formam.ReplaceCollections, NumberFormat: &formam.NumberFormatGerman, MaxSize: 100},
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		vals, err := url.ParseQuery(query)
+		if err != nil {
+			return
+		}
+		for i := range options {
+			opts := options[i]
+			dec := formam.NewDecoder(&opts).RegisterCustomType(func(vals []string) (interface{}, error) {
+				return FieldString(vals[0]), nil
+			}, []interface{}{FieldString("")}, nil)
+			for _, dst := range newTargets() {
+				err := dec.Decode(vals, dst)
+				switch err.(type) {
+				case nil, *formam.Error, formam.Errors:
+				default:
+					t.Errorf("%T is not an *Error: %v", err, err)
+				}
+			}
+			// DecodeString decodes the keys as they are read
+			for _, dst := range newTargets() {
+				err := dec.DecodeString(query, dst)
+				switch err.(type) {
+				case nil, *formam.Error, formam.Errors:
+				default:
+					t.Errorf("DecodeString: %T is not an *Error: %v", err, err)
+				}
+			}
+		}
+	})
+}
@@ -1,3 +1,3 @@
 module github.com/monoculum/formam/v3
 
-go 1.12
+go 1.15
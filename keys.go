@@ -0,0 +1,86 @@
+package formam
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+)
+
+// DecodeKeyFunc decodes a map key of the type typ from its text in the form,
+// such as "1,2" in grid[1,2].
+type DecodeKeyFunc func(key string, typ reflect.Type) (interface{}, error)
+
+var typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// RegisterKeyDecoder registers a function for decoding the map keys of the
+// types. It has preference over the custom types and UnmarshalText, which
+// are also used for the values, so a type can be decoded in a different way
+// as a key.
+func (dec *Decoder) RegisterKeyDecoder(fn DecodeKeyFunc, types []interface{}) *Decoder {
+	if dec.keyDecoders == nil {
+		dec.keyDecoders = make(map[reflect.Type]DecodeKeyFunc)
+	}
+	for i := range types {
+		dec.keyDecoders[reflect.TypeOf(types[i])] = fn
+	}
+	return dec
+}
+
+// decodeKey sets the map key in the current value, which must be settable.
+//
+// A struct without custom type or UnmarshalText is decoded from a composite
+// key, with the values of its exported fields in order separated by commas;
+// e.g. "1,2" for struct{ X, Y int }.
+func (dec *Decoder) decodeKey(key string) error {
+	typ := dec.curr.Type()
+	if fn, ok := dec.keyDecoders[typ]; ok {
+		return dec.setCustomType(fn(key, typ))
+	}
+	if typ.Kind() == reflect.Ptr && dec.customTypes[typ] == nil {
+		dec.curr.Set(reflect.New(typ.Elem()))
+		dec.curr = dec.curr.Elem()
+		return dec.decodeKey(key)
+	}
+	if dec.isCompositeKey(typ) {
+		return dec.decodeCompositeKey(key)
+	}
+	return dec.decode()
+}
+
+// isCompositeKey reports if the map keys of type typ are decoded from a
+// composite key.
+func (dec *Decoder) isCompositeKey(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ == typeTime || typ == typeURL || dec.customTypes[typ] != nil {
+		return false
+	}
+	return dec.opts.DisableUnmarshalText || !reflect.PtrTo(typ).Implements(typeTextUnmarshaler)
+}
+
+// decodeCompositeKey sets the exported fields of the current struct with the
+// values of the key separated by commas.
+func (dec *Decoder) decodeCompositeKey(key string) error {
+	st, typ := dec.curr, dec.curr.Type()
+	var fields []int
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath == "" && f.Tag.Get(dec.opts.TagName) != "-" {
+			fields = append(fields, i)
+		}
+	}
+	values := strings.Split(key, ",")
+	if len(values) != len(fields) {
+		return dec.newError(ErrCodeConversion, "key has %d values for %d fields", len(values), len(fields))
+	}
+
+	for j, i := range fields {
+		dec.curr = st.Field(i)
+		dec.structField = typ.Field(i)
+		dec.structType = typ
+		dec.currValues = values[j : j+1]
+		if err := dec.decode(); err != nil {
+			return err
+		}
+	}
+	dec.curr = st
+	return nil
+}
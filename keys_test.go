@@ -0,0 +1,113 @@
+package formam_test
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+type Point struct {
+	X, Y int
+}
+
+type Cell struct {
+	Row    uint8
+	Col    string
+	hidden bool
+	Skip   string `formam:"-"`
+}
+
+// Code is decoded in upper case as a value and in lower case as a key.
+type Code string
+
+func TestCompositeKeys(t *testing.T) {
+	s := struct {
+		Grid  map[Point]int
+		Cells map[*Cell]string
+		Sets  map[Point]map[Cell]bool
+	}{}
+	err := formam.Decode(url.Values{
+		"Grid[1,2]":      {"5"},
+		"Grid[-3,0]":     {"7"},
+		"Cells[2,B]":     {"x"},
+		"Sets[0,0][1,A]": {"true"},
+	}, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Grid, map[Point]int{{1, 2}: 5, {-3, 0}: 7}) {
+		t.Errorf("Grid is %v", s.Grid)
+	}
+	if len(s.Cells) != 1 {
+		t.Errorf("Cells is %v", s.Cells)
+	}
+	for k, v := range s.Cells {
+		if *k != (Cell{Row: 2, Col: "B"}) || v != "x" {
+			t.Errorf("Cells has %+v: %s", *k, v)
+		}
+	}
+	if !s.Sets[Point{}][Cell{Row: 1, Col: "A"}] {
+		t.Errorf("Sets is %v", s.Sets)
+	}
+
+	for _, key := range []string{"Grid[1]", "Grid[1,2,3]", "Grid[a,1]"} {
+		err := formam.Decode(url.Values{key: {"1"}}, &s)
+		if e, ok := err.(*formam.Error); !ok || e.Code() != formam.ErrCodeConversion {
+			t.Errorf("%s: wrong error: %v", key, err)
+		}
+	}
+}
+
+func TestKeyDecoder(t *testing.T) {
+	s := struct {
+		Name  Code
+		Codes map[Code]Code
+		Grid  map[Point]string
+		Ptrs  map[*Point]string
+	}{}
+	dec := formam.NewDecoder(nil).
+		RegisterCustomType(func(vals []string) (interface{}, error) {
+			return Code(strings.ToUpper(vals[0])), nil
+		}, []interface{}{Code("")}, nil).
+		RegisterKeyDecoder(func(key string, typ reflect.Type) (interface{}, error) {
+			if typ != reflect.TypeOf(Code("")) {
+				t.Errorf("key type is %v", typ)
+			}
+			return Code(strings.ToLower(key)), nil
+		}, []interface{}{Code("")}).
+		RegisterKeyDecoder(func(key string, typ reflect.Type) (interface{}, error) {
+			var p Point
+			if key != "origin" {
+				return nil, errors.New("unknown point")
+			}
+			return p, nil
+		}, []interface{}{Point{}})
+
+	err := dec.Decode(url.Values{
+		"Name":         {"abc"},
+		"Codes[EsP]":   {"spain"},
+		"Grid[origin]": {"o"},
+		"Ptrs[origin]": {"p"},
+	}, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "ABC" || !reflect.DeepEqual(s.Codes, map[Code]Code{"esp": "SPAIN"}) || s.Grid[Point{}] != "o" {
+		t.Errorf("%+v", s)
+	}
+	for k, v := range s.Ptrs {
+		if *k != (Point{}) || v != "p" {
+			t.Errorf("Ptrs has %v: %s", *k, v)
+		}
+	}
+
+	err = dec.Decode(url.Values{"Grid[1,2]": {"x"}}, &s)
+	var e *formam.Error
+	if !errors.As(err, &e) || e.Code() != formam.ErrCodeConversion || e.Path() != "Grid[1,2]" || e.Value() != "1,2" {
+		t.Errorf("wrong error: %v", err)
+	}
+}
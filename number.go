@@ -0,0 +1,149 @@
+package formam
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// NumberFormat are the separators used to write numbers, to decode the
+// numbers written for a locale, such as 1.234,56 in German.
+type NumberFormat struct {
+	// Decimal separator.
+	Decimal string
+
+	// Characters used to group the digits of the integer part by three; any
+	// of them can be used and none is required. Empty disallows grouping.
+	Grouping string
+}
+
+// Number formats of some locales.
+var (
+	NumberFormatEnglish = NumberFormat{Decimal: ".", Grouping: ","}
+	NumberFormatGerman  = NumberFormat{Decimal: ",", Grouping: "."}
+	NumberFormatFrench  = NumberFormat{Decimal: ",", Grouping: " \u00a0\u202f"}
+	NumberFormatSwiss   = NumberFormat{Decimal: ".", Grouping: "'"}
+)
+
+// numberFormats are the number formats for the "number" tag option, by
+// language code, or by locale when it differs from the language.
+var numberFormats = map[string]NumberFormat{
+	"en":    NumberFormatEnglish,
+	"de":    NumberFormatGerman,
+	"es":    NumberFormatGerman,
+	"it":    NumberFormatGerman,
+	"nl":    NumberFormatGerman,
+	"pt":    NumberFormatGerman,
+	"fr":    NumberFormatFrench,
+	"de-CH": NumberFormatSwiss,
+}
+
+// RegisterNumberFormat registers a number format for the "number" tag option,
+// which overrides the NumberFormat option for a field; e.g. with:
+//
+//	Price float64 `formam:"price,number=de"`
+//
+// The names en, de, es, it, nl, pt, fr and de-CH are available without
+// registering them.
+func (dec *Decoder) RegisterNumberFormat(name string, f NumberFormat) *Decoder {
+	if dec.numberFormats == nil {
+		dec.numberFormats = make(map[string]NumberFormat)
+	}
+	dec.numberFormats[name] = f
+	return dec
+}
+
+// number returns the number in v with the syntax of strconv, using the
+// number format of the current field or the NumberFormat option.
+func (dec *Decoder) number(v string) (string, error) {
+	f := dec.opts.NumberFormat
+	if name, ok := tagOption(dec.structField.Tag, dec.opts.TagName, "number"); ok {
+		nf, ok := dec.numberFormats[name]
+		if !ok {
+			if nf, ok = numberFormats[name]; !ok {
+				return "", dec.newError(ErrCodeUnknownType, "unknown number format %q", name)
+			}
+		}
+		f = &nf
+	}
+	if f == nil {
+		return v, nil
+	}
+	n, ok := f.normalize(v)
+	if !ok {
+		return "", dec.newError(ErrCodeConversion, "could not parse number: invalid grouping")
+	}
+	return n, nil
+}
+
+// integer returns the integer in v with the syntax of strconv and its base,
+// from the "base" tag option of the current field or the IntegerPrefixes
+// option. The number format is only used for base 10.
+func (dec *Decoder) integer(v string) (string, int, error) {
+	base := 10
+	if dec.opts.IntegerPrefixes {
+		base = 0
+	}
+	if s, ok := tagOption(dec.structField.Tag, dec.opts.TagName, "base"); ok {
+		b, err := strconv.Atoi(s)
+		if err != nil || b < 0 || b == 1 || b > 36 {
+			return "", 0, dec.newError(ErrCodeUnknownType, "invalid base %q", s)
+		}
+		base = b
+	}
+	if base != 10 {
+		return v, base, nil
+	}
+	v, err := dec.number(v)
+	return v, base, err
+}
+
+// normalize returns the number in v with the syntax of strconv, and false if
+// its grouping separators are misplaced, such as in 1.5 with
+// NumberFormatGerman. Other invalid numbers are returned as is, to be
+// rejected by strconv.
+func (f NumberFormat) normalize(v string) (string, bool) {
+	integer, fraction := v, ""
+	if f.Decimal != "" {
+		if p := strings.Index(v, f.Decimal); p != -1 {
+			integer, fraction = v[:p], v[p+len(f.Decimal):]
+			if strings.Contains(fraction, f.Decimal) || strings.ContainsAny(fraction, f.Grouping) {
+				return v, true
+			}
+		}
+	}
+
+	var b strings.Builder
+	digits := 0      // digits since the last separator
+	grouped := false // a separator was found
+	for i, r := range integer {
+		if f.Grouping != "" && strings.ContainsRune(f.Grouping, r) {
+			// a separator must be between digits, and after the first group
+			// the groups have three digits
+			next := i + utf8.RuneLen(r)
+			if i == 0 || next == len(integer) || !isDigit(integer[i-1]) || !isDigit(integer[next]) ||
+				digits > 3 || (grouped && digits != 3) {
+				return v, false
+			}
+			digits, grouped = 0, true
+			continue
+		}
+		if r < utf8.RuneSelf && isDigit(byte(r)) {
+			digits++
+		}
+		b.WriteRune(r)
+	}
+	if grouped && digits != 3 {
+		return v, false
+	}
+	if fraction != "" || integer != v {
+		b.WriteByte('.')
+		b.WriteString(fraction)
+	}
+	return b.String(), true
+}
+
+// isDigit reports if c is a decimal digit.
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,170 @@
+package formam_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+func TestNumberFormat(t *testing.T) {
+	type numbers struct {
+		Int    int
+		Uint   uint16
+		Float  float64
+		Floats []float32
+		Price  float64 `formam:"price,number=de"`
+		Rate   float64 `formam:"rate,number=en"`
+		Count  int     `formam:"count,number=en"`
+		Swiss  int     `formam:"swiss,number=de-CH"`
+		French float64 `formam:"french,number=fr"`
+		Custom float64 `formam:"custom,number=x"`
+	}
+
+	s := numbers{}
+	dec := formam.NewDecoder(&formam.DecoderOptions{NumberFormat: &formam.NumberFormatGerman}).
+		RegisterNumberFormat("x", formam.NumberFormat{Decimal: "·", Grouping: "_"})
+	err := dec.Decode(url.Values{
+		"Int":      []string{"-1.234.567"},
+		"Uint":     []string{"65.535"},
+		"Float":    []string{"1.234,5"},
+		"Floats[]": []string{"0,25", "3"},
+		"price":    []string{"9.999,99"},
+		"rate":     []string{"1,000.5"},
+		"swiss":    []string{"1'000'000"},
+		"french":   []string{"1 234,5"},
+		"custom":   []string{"1_000·5"},
+	}, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := numbers{
+		Int:    -1234567,
+		Uint:   65535,
+		Float:  1234.5,
+		Floats: []float32{0.25, 3},
+		Price:  9999.99,
+		Rate:   1000.5,
+		Swiss:  1000000,
+		French: 1234.5,
+		Custom: 1000.5,
+	}
+	if s.Int != want.Int || s.Uint != want.Uint || s.Float != want.Float ||
+		len(s.Floats) != 2 || s.Floats[0] != want.Floats[0] || s.Floats[1] != want.Floats[1] ||
+		s.Price != want.Price || s.Rate != want.Rate || s.Swiss != want.Swiss ||
+		s.French != want.French || s.Custom != want.Custom {
+		t.Errorf("\nhave: %+v\nwant: %+v", s, want)
+	}
+
+	// without the option the numbers have the syntax of strconv, except in
+	// the fields with a number format.
+	s = numbers{}
+	err = formam.NewDecoder(nil).Decode(url.Values{"Float": []string{"1.5"}, "price": []string{"1.000"}}, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Float != 1.5 || s.Price != 1000 {
+		t.Errorf("Float is %v and Price is %v", s.Float, s.Price)
+	}
+
+	for _, tt := range []struct {
+		key, value string
+		code       formam.ErrorCode
+	}{
+		{"Int", "1.2.3,4", formam.ErrCodeConversion},
+		{"Int", ".123", formam.ErrCodeConversion},
+		{"Int", "123.", formam.ErrCodeConversion},
+		{"Float", "1,2.5", formam.ErrCodeConversion},
+		{"Float", "1,2,5", formam.ErrCodeConversion},
+		{"Uint", "65.536", formam.ErrCodeRange},
+		{"rate", "1.000,5", formam.ErrCodeConversion},
+		// the groups after the first one have three digits
+		{"Int", "1.5", formam.ErrCodeConversion},
+		{"Float", "1.5", formam.ErrCodeConversion},
+		{"Int", "1.23", formam.ErrCodeConversion},
+		{"Int", "1.2345", formam.ErrCodeConversion},
+		{"Int", "1234.567", formam.ErrCodeConversion},
+		{"Float", "1.000.00,5", formam.ErrCodeConversion},
+		{"rate", "1,5", formam.ErrCodeConversion},
+		{"count", "1,5", formam.ErrCodeConversion},
+		{"count", "12,34,567", formam.ErrCodeConversion},
+	} {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			err := dec.Decode(url.Values{tt.key: []string{tt.value}}, &numbers{})
+			if err == nil {
+				t.Fatal("no error")
+			}
+			if code := err.(*formam.Error).Code(); code != tt.code {
+				t.Errorf("code is %d, want %d: %s", code, tt.code, err)
+			}
+		})
+	}
+
+	s2 := struct {
+		N int `formam:"n,number=xx"`
+	}{}
+	err = formam.NewDecoder(nil).Decode(url.Values{"n": []string{"1"}}, &s2)
+	if err == nil || err.(*formam.Error).Code() != formam.ErrCodeUnknownType {
+		t.Errorf("unknown number format: %v", err)
+	}
+}
+
+func TestIntegerBase(t *testing.T) {
+	type integers struct {
+		Int    int
+		Uint   uint8
+		Color  uint32 `formam:"color,base=16"`
+		Mode   uint16 `formam:"mode,base=8"`
+		Mask   []uint `formam:"mask,base=2"`
+		Prefix int    `formam:"prefix,base=0"`
+		Dec    int    `formam:"dec,base=10"`
+	}
+
+	s := integers{}
+	err := formam.NewDecoder(&formam.DecoderOptions{IntegerPrefixes: true}).Decode(url.Values{
+		"Int":    []string{"-0x1_000"},
+		"Uint":   []string{"0b1010"},
+		"color":  []string{"FF8800"},
+		"mode":   []string{"755"},
+		"mask[]": []string{"101", "11"},
+		"prefix": []string{"0o17"},
+		"dec":    []string{"010"},
+	}, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Int != -4096 || s.Uint != 10 || s.Color != 0xFF8800 || s.Mode != 0755 ||
+		len(s.Mask) != 2 || s.Mask[0] != 5 || s.Mask[1] != 3 || s.Prefix != 15 || s.Dec != 10 {
+		t.Errorf("%+v", s)
+	}
+
+	// the tag option is used without the option, and the prefixes are not
+	s = integers{}
+	err = formam.NewDecoder(nil).Decode(url.Values{"prefix": []string{"0x10"}, "Int": []string{"010"}}, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Prefix != 16 || s.Int != 10 {
+		t.Errorf("Prefix is %d and Int is %d", s.Prefix, s.Int)
+	}
+	err = formam.NewDecoder(nil).Decode(url.Values{"Int": []string{"0x10"}}, &s)
+	if err == nil || err.(*formam.Error).Code() != formam.ErrCodeConversion {
+		t.Errorf("prefix without the option: %v", err)
+	}
+	err = formam.NewDecoder(nil).Decode(url.Values{"Uint": []string{"0x100"}}, &integers{})
+	if err == nil || err.(*formam.Error).Code() != formam.ErrCodeConversion {
+		t.Errorf("prefix without the option: %v", err)
+	}
+	err = formam.NewDecoder(&formam.DecoderOptions{IntegerPrefixes: true}).Decode(url.Values{"Uint": []string{"0x100"}}, &integers{})
+	if err == nil || err.(*formam.Error).Code() != formam.ErrCodeRange {
+		t.Errorf("out of range: %v", err)
+	}
+
+	s2 := struct {
+		N int `formam:"n,base=1"`
+	}{}
+	err = formam.NewDecoder(nil).Decode(url.Values{"n": []string{"1"}}, &s2)
+	if err == nil || err.(*formam.Error).Code() != formam.ErrCodeUnknownType {
+		t.Errorf("invalid base: %v", err)
+	}
+}
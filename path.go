@@ -0,0 +1,228 @@
+package formam
+
+import (
+	"errors"
+	"strings"
+)
+
+// SegmentKind is the kind of a PathSegment.
+type SegmentKind uint8
+
+// Kinds of path segments.
+const (
+	// FieldSegment accesses a struct field, or a map key for backward
+	// compatibility; e.g. the "b" in a.b.
+	FieldSegment SegmentKind = iota
+
+	// IndexSegment accesses a slice or array index or a map key; e.g. the
+	// "0" in a[0]. The name is empty to append values, as in a[].
+	IndexSegment
+
+	// KeySegment is like IndexSegment, but it can also access a struct
+	// field; e.g. the "b" in a[b] for the BracketPathParser.
+	KeySegment
+)
+
+// PathSegment is one step of the path to a value.
+type PathSegment struct {
+	Kind SegmentKind
+	Name string
+}
+
+// PathParser splits the path in a form key into the segments to walk through
+// to reach the value.
+//
+// An empty IndexSegment or KeySegment followed by more segments decodes each
+// value in its own element of the slice; e.g. with rows[][name]=a and
+// rows[][name]=b the first element gets the name a and the second b.
+type PathParser interface {
+	ParsePath(path string) ([]PathSegment, error)
+}
+
+// DefaultPathParser parses paths which use dots to access struct fields and
+// brackets to access slice and array indexes and map keys, such as
+// users[0].name or map[key]. Dots inside brackets are part of the key.
+//
+// A backslash escapes a dot, bracket, double quote or backslash, and the key
+// in brackets can be quoted: both m[a\.b\]] and m["a.b]"] access the key
+// a.b] of the map m, and m.a\.b accesses the key a.b. Use FormatPath to
+// build paths which round-trip any key.
+type DefaultPathParser struct{}
+
+// ParsePath implements the interface PathParser
+func (p DefaultPathParser) ParsePath(path string) ([]PathSegment, error) {
+	return p.appendPath(make([]PathSegment, 0, 4), path)
+}
+
+func (DefaultPathParser) appendPath(segs []PathSegment, path string) ([]PathSegment, error) {
+	return parsePath(segs, path, true, IndexSegment, false)
+}
+
+// BracketPathParser parses paths which only use brackets to access struct
+// fields, slice and array indexes and map keys, as sent by PHP, the qs and
+// jQuery.param JavaScript libraries and Rails; e.g. user[address][city],
+// tags[] or user[addresses][][city]. Dots are part of the names unless
+// AllowDots is set.
+//
+// Keys are escaped and quoted like in DefaultPathParser.
+type BracketPathParser struct {
+	// Also use dots to access struct fields and map keys, like the
+	// allowDots option of qs; e.g. user.address[city].
+	AllowDots bool
+}
+
+// ParsePath implements the interface PathParser
+func (p BracketPathParser) ParsePath(path string) ([]PathSegment, error) {
+	return p.appendPath(make([]PathSegment, 0, 4), path)
+}
+
+func (p BracketPathParser) appendPath(segs []PathSegment, path string) ([]PathSegment, error) {
+	return parsePath(segs, path, p.AllowDots, KeySegment, true)
+}
+
+// pathAppender is implemented by the path parsers of this package to append
+// the segments to a slice reused for every path.
+type pathAppender interface {
+	appendPath(segs []PathSegment, path string) ([]PathSegment, error)
+}
+
+// parsePath splits the path in segments and appends them to segs. Dots separate fields if dots is
+// true, and the contents of brackets are segments of the given kind. If
+// strict is true then a closing bracket can only be followed by another
+// bracket or a dot.
+func parsePath(segs []PathSegment, path string, dots bool, kind SegmentKind, strict bool) ([]PathSegment, error) {
+	stop := &stopField
+	if dots {
+		stop = &stopDottedField
+	}
+
+	closed := false // just after a closing bracket
+	for i := 0; i < len(path); {
+		switch c := path[i]; {
+		case c == '.' && dots:
+			i++
+			closed = false
+			continue
+		case c == '[':
+			name, n, err := parseKey(path[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, PathSegment{Kind: kind, Name: name})
+			i += n + 1
+			closed = true
+			continue
+		case c == ']':
+			return nil, errors.New("closing bracket without opening bracket")
+		case closed && strict:
+			return nil, errors.New("closing bracket is not followed by a bracket")
+		}
+
+		name, n := parseName(path[i:], stop)
+		segs = appendField(segs, name)
+		i += n
+		closed = false
+	}
+	return segs, nil
+}
+
+// parseKey parses the key inside brackets at the start of s, which must be
+// just after the opening bracket. It returns the key and the number of bytes
+// read, including the closing bracket.
+func parseKey(s string) (string, int, error) {
+	var key string
+	var n int
+	if strings.HasPrefix(s, `"`) {
+		key, n = parseName(s[1:], &stopQuotedKey)
+		if n+1 >= len(s) {
+			return "", 0, errors.New("quote is not closed")
+		}
+		n += 2
+	} else {
+		key, n = parseName(s, &stopKey)
+	}
+	if n >= len(s) || s[n] != ']' {
+		return "", 0, errors.New("bracket is not closed")
+	}
+	return key, n + 1, nil
+}
+
+// parseName parses the name at the start of s until any of the bytes in stop
+// or the end of s, and unescapes it. It returns the name and the number of
+// bytes read.
+func parseName(s string, stop *[256]bool) (string, int) {
+	i := 0
+	for i < len(s) && !stop[s[i]] && s[i] != '\\' {
+		i++
+	}
+	if i == len(s) || s[i] != '\\' {
+		// fast path: nothing to unescape
+		return s[:i], i
+	}
+
+	var b strings.Builder
+	b.WriteString(s[:i])
+	for ; i < len(s) && !stop[s[i]]; i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(escaped, s[i+1]) != -1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), i
+}
+
+// escaped are the bytes that can be escaped with a backslash in paths.
+const escaped = `\.[]"`
+
+// Bytes that end a name in parseName.
+var (
+	stopField       = byteSet("[]")
+	stopDottedField = byteSet(".[]")
+	stopKey         = byteSet("]")
+	stopQuotedKey   = byteSet(`"`)
+)
+
+// byteSet returns a set with the bytes in s.
+func byteSet(s string) (set [256]bool) {
+	for i := 0; i < len(s); i++ {
+		set[s[i]] = true
+	}
+	return set
+}
+
+// FormatPath formats the segments as a path for the DefaultPathParser,
+// escaping the names as needed to round-trip them.
+func FormatPath(segs []PathSegment) string {
+	var b strings.Builder
+	for i, seg := range segs {
+		if seg.Kind == FieldSegment {
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			writeEscaped(&b, seg.Name, escaped)
+		} else {
+			b.WriteByte('[')
+			writeEscaped(&b, seg.Name, `\]"`)
+			b.WriteByte(']')
+		}
+	}
+	return b.String()
+}
+
+// writeEscaped writes s to b escaping the bytes in special with a backslash.
+func writeEscaped(b *strings.Builder, s, special string) {
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(special, s[i]) != -1 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+}
+
+// appendField appends the field name to segs, unless it is empty.
+func appendField(segs []PathSegment, name string) []PathSegment {
+	if name == "" {
+		return segs
+	}
+	return append(segs, PathSegment{Kind: FieldSegment, Name: name})
+}
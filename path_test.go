@@ -0,0 +1,130 @@
+package formam_test
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+func TestParsePath(t *testing.T) {
+	field := func(n string) formam.PathSegment { return formam.PathSegment{Kind: formam.FieldSegment, Name: n} }
+	index := func(n string) formam.PathSegment { return formam.PathSegment{Kind: formam.IndexSegment, Name: n} }
+	key := func(n string) formam.PathSegment { return formam.PathSegment{Kind: formam.KeySegment, Name: n} }
+
+	tests := []struct {
+		parser    formam.PathParser
+		in        string
+		want      []formam.PathSegment
+		wantError string
+	}{
+		{formam.DefaultPathParser{}, "", []formam.PathSegment{}, ""},
+		{formam.DefaultPathParser{}, "a", []formam.PathSegment{field("a")}, ""},
+		{formam.DefaultPathParser{}, "a.b", []formam.PathSegment{field("a"), field("b")}, ""},
+		{formam.DefaultPathParser{}, "a[0][1].b", []formam.PathSegment{field("a"), index("0"), index("1"), field("b")}, ""},
+		{formam.DefaultPathParser{}, "a[x.y]", []formam.PathSegment{field("a"), index("x.y")}, ""},
+		{formam.DefaultPathParser{}, "a[]", []formam.PathSegment{field("a"), index("")}, ""},
+		{formam.DefaultPathParser{}, "a[1][]", []formam.PathSegment{field("a"), index("1"), index("")}, ""},
+		{formam.DefaultPathParser{}, "[0].a", []formam.PathSegment{index("0"), field("a")}, ""},
+		{formam.DefaultPathParser{}, "a.", []formam.PathSegment{field("a")}, ""},
+		{formam.DefaultPathParser{}, "a[", nil, "bracket is not closed"},
+		{formam.DefaultPathParser{}, "a]b[", nil, "closing bracket without opening bracket"},
+		{formam.DefaultPathParser{}, `a\.b.c`, []formam.PathSegment{field("a.b"), field("c")}, ""},
+		{formam.DefaultPathParser{}, `a[b\]c][\\]`, []formam.PathSegment{field("a"), index("b]c"), index(`\`)}, ""},
+		{formam.DefaultPathParser{}, `a["b]c"]["\""][""]`, []formam.PathSegment{field("a"), index("b]c"), index(`"`), index("")}, ""},
+		{formam.DefaultPathParser{}, `a[C:\dir]`, []formam.PathSegment{field("a"), index(`C:\dir`)}, ""},
+		{formam.DefaultPathParser{}, `a["b]`, nil, "quote is not closed"},
+		{formam.DefaultPathParser{}, `a["b"c]`, nil, "bracket is not closed"},
+		{formam.DefaultPathParser{}, `a[b\]`, nil, "bracket is not closed"},
+
+		{formam.BracketPathParser{}, "a[b][c]", []formam.PathSegment{field("a"), key("b"), key("c")}, ""},
+		{formam.BracketPathParser{}, "a.b[c.d]", []formam.PathSegment{field("a.b"), key("c.d")}, ""},
+		{formam.BracketPathParser{}, "a[][b]", []formam.PathSegment{field("a"), key(""), key("b")}, ""},
+		{formam.BracketPathParser{}, "a[b]c", nil, "closing bracket is not followed by a bracket"},
+		{formam.BracketPathParser{}, `a[b\[c]["d]"]`, []formam.PathSegment{field("a"), key("b[c"), key("d]")}, ""},
+		{formam.BracketPathParser{AllowDots: true}, "a.b[c].d", []formam.PathSegment{field("a"), field("b"), key("c"), field("d")}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T %s", tt.parser, tt.in), func(t *testing.T) {
+			out, err := tt.parser.ParsePath(tt.in)
+			if !errorContains(err, tt.wantError) {
+				t.Fatalf("wrong error: %s", err)
+			}
+			if !reflect.DeepEqual(out, tt.want) {
+				t.Errorf("\nout:  %#v\nwant: %#v", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	keys := []string{"", "a", "a.b", "a[b]", `a\.b`, `"a"`, `\`, "X-Forwarded.For"}
+	for _, k := range keys {
+		t.Run(k, func(t *testing.T) {
+			segs := []formam.PathSegment{
+				{Kind: formam.FieldSegment, Name: "m" + k},
+				{Kind: formam.IndexSegment, Name: k},
+				{Kind: formam.FieldSegment, Name: "m" + k},
+			}
+			path := formam.FormatPath(segs)
+			out, err := formam.DefaultPathParser{}.ParsePath(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out, segs) {
+				t.Errorf("%s\nout:  %#v\nwant: %#v", path, out, segs)
+			}
+		})
+	}
+}
+
+func TestEscapedMapKeys(t *testing.T) {
+	s := struct {
+		M map[string]string
+	}{}
+
+	vals := url.Values{
+		`M[X-Forwarded.For]`: []string{"1"},
+		`M["a]b"]`:           []string{"2"},
+		`M[c\]d]`:            []string{"3"},
+		`M.e\.f`:             []string{"4"},
+		`M.g\[h\]`:           []string{"5"},
+	}
+	if err := formam.NewDecoder(nil).Decode(vals, &s); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"X-Forwarded.For": "1", "a]b": "2", "c]d": "3", "e.f": "4", "g[h]": "5"}
+	if !reflect.DeepEqual(s.M, want) {
+		t.Errorf("\nout:  %#v\nwant: %#v", s.M, want)
+	}
+}
+
+func TestBracketPathParser(t *testing.T) {
+	type Address struct {
+		City string `formam:"city"`
+	}
+	s := struct {
+		User struct {
+			Name      string    `formam:"name"`
+			Addresses []Address `formam:"addresses"`
+			Tags      []string  `formam:"tags"`
+			Meta      map[string]string
+		} `formam:"user"`
+	}{}
+
+	dec := formam.NewDecoder(&formam.DecoderOptions{PathParser: formam.BracketPathParser{}})
+	err := dec.DecodeString("user[name]=Homer&user[addresses][][city]=Springfield&user[addresses][][city]=Capital+City"+
+		"&user[tags][]=dad&user[tags][]=safety&user[Meta][in.dots]=x", &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := fmt.Sprintf("%v", s)
+	want := `{{Homer [{Springfield} {Capital City}] [dad safety] map[in.dots:x]}}`
+	if out != want {
+		t.Fatalf("\nout:  %s\nwant: %s", out, want)
+	}
+}
@@ -0,0 +1,133 @@
+package formam
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// DecodeString decodes the application/x-www-form-urlencoded query and
+// populates the destination dst, which must be a pointer.
+//
+// Unlike Decode, the keys are decoded in the order they first appear in the
+// query.
+func (dec Decoder) DecodeString(query string, dst interface{}) error {
+	return dec.DecodeReader(strings.NewReader(query), dst)
+}
+
+// DecodeReader decodes the application/x-www-form-urlencoded pairs read from
+// r, such as a request body, and populates the destination dst, which must be
+// a pointer.
+//
+// Unlike Decode, every key is decoded as soon as it's read, in the order of
+// the input. The values of a repeated key are added after the ones decoded
+// before, in the elements of slices and arrays, and the other values keep
+// the first one, as with Decode. A key under a union whose discriminator
+// comes later is decoded when all the input is read, and so are the values
+// of the maps, UnmarshalForm and Validate. No more than MaxFormSize bytes
+// are read.
+func (dec Decoder) DecodeReader(r io.Reader, dst interface{}) error {
+	main := reflect.ValueOf(dst)
+	if main.Kind() != reflect.Ptr {
+		return newError(ErrCodeNotAPointer, "", "", "dst %q is not a pointer", main.Kind())
+	}
+	if dec.opts.MaxFormSize >= 0 {
+		r = &limitReader{r: r, max: dec.opts.MaxFormSize}
+	}
+
+	dec.main = main.Elem()
+	dec.values = make(url.Values)
+	dec.keys = []string{}
+	dec.streaming = true
+	return dec.translate(dec.run(func() error {
+		err := parseQuery(r, func(key, value string) error {
+			vs := dec.values[key]
+			if len(vs) == 0 {
+				dec.keys = append(dec.keys, key)
+			}
+			dec.values[key] = append(vs, value)
+			if dec.skipped[key] {
+				return nil
+			}
+			dec.skip = len(vs)
+			return dec.decodePath(key)
+		})
+		if err != nil {
+			return err
+		}
+
+		// the discriminators of the unions are all read
+		dec.streaming = false
+		for _, p := range dec.pending {
+			dec.skip = p.skip
+			if err := dec.decodePath(p.key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+// pendingKey is a key that DecodeReader decodes when all the input is read,
+// from the value at skip.
+type pendingKey struct {
+	key  string
+	skip int
+}
+
+// parseQuery reads the application/x-www-form-urlencoded pairs from r and
+// calls fn with every unescaped pair in the order they are found, until it
+// returns an error.
+func parseQuery(r io.Reader, fn func(key, value string) error) error {
+	br := bufio.NewReader(r)
+	for {
+		pair, err := br.ReadString('&')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		pair = strings.TrimSuffix(pair, "&")
+		if pair != "" {
+			key, value := pair, ""
+			if i := strings.IndexByte(pair, '='); i != -1 {
+				key, value = pair[:i], pair[i+1:]
+			}
+			k, kErr := url.QueryUnescape(key)
+			if kErr != nil {
+				return newError(ErrCodeSyntax, "", key, "could not unescape key: %w", kErr)
+			}
+			v, vErr := url.QueryUnescape(value)
+			if vErr != nil {
+				return &Error{code: ErrCodeSyntax, field: k, path: k, value: value, err: fmt.Errorf("could not unescape value: %w", vErr)}
+			}
+			if err := fn(k, v); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// limitReader reads from r but fails with an error of code ErrCodeFormSize
+// when there are more than max bytes to read.
+type limitReader struct {
+	r    io.Reader
+	max  int64 // maximum number of bytes
+	read int64 // number of bytes read so far
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if left := l.max - l.read + 1; int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		return 0, newError(ErrCodeFormSize, "", "", "form is longer than MaxFormSize %d", l.max)
+	}
+	return n, err
+}
@@ -0,0 +1,234 @@
+package formam_test
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+func TestDecodeString(t *testing.T) {
+	s := struct {
+		Name   string
+		Tags   []string
+		Prices map[string]float64
+	}{}
+
+	dec := formam.NewDecoder(nil)
+	err := dec.DecodeString("Name=Homer+Simpson&Tags=b&Prices%5Bbeer%5D=2.5&Tags=a&&Tags", &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "Homer Simpson" {
+		t.Errorf("Name is %q", s.Name)
+	}
+	if strings.Join(s.Tags, ",") != "b,a," {
+		t.Errorf("Tags is %q", s.Tags)
+	}
+	if s.Prices["beer"] != 2.5 {
+		t.Errorf("Prices is %v", s.Prices)
+	}
+}
+
+func TestDecodeStringOrder(t *testing.T) {
+	s := struct {
+		A int
+		B int
+	}{}
+
+	for _, tt := range []struct {
+		in, wantPath string
+	}{
+		{"B=x&A=y", "B"},
+		{"A=y&B=x", "A"},
+		{"A=1&B=x&A=y", "B"},
+	} {
+		t.Run(tt.in, func(t *testing.T) {
+			err := formam.NewDecoder(nil).DecodeString(tt.in, &s)
+			if err == nil {
+				t.Fatal("error is nil")
+			}
+			if p := err.(*formam.Error).Path(); p != tt.wantPath {
+				t.Errorf("wrong path in error: %q", p)
+			}
+		})
+	}
+}
+
+func TestDecodeReader(t *testing.T) {
+	var s struct{ Name string }
+
+	tests := []struct {
+		in          string
+		maxFormSize int64
+		wantCode    formam.ErrorCode
+		wantError   string
+	}{
+		{"Name=Homer", 0, 0, ""},
+		{"Name=Homer", 10, 0, ""},
+		{"Name=Homer", 9, formam.ErrCodeFormSize, "form is longer than MaxFormSize 9"},
+		{strings.Repeat("Name=Homer&", 1000), -1, 0, ""},
+		{"Name=%zz", 0, formam.ErrCodeSyntax, "could not unescape value"},
+		{"Na%me=Homer", 0, formam.ErrCodeSyntax, "could not unescape key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			dec := formam.NewDecoder(&formam.DecoderOptions{MaxFormSize: tt.maxFormSize})
+			err := dec.DecodeReader(strings.NewReader(tt.in), &s)
+			if !errorContains(err, tt.wantError) {
+				t.Fatalf("wrong error: %s", err)
+			}
+			if err != nil {
+				if c := err.(*formam.Error).Code(); c != tt.wantCode {
+					t.Errorf("error code is %d", c)
+				}
+				return
+			}
+			if s.Name != "Homer" {
+				t.Errorf("Name is %q", s.Name)
+			}
+		})
+	}
+}
+
+func TestDecodeReaderUnescapeError(t *testing.T) {
+	var s struct{ Name string }
+	for _, tt := range []struct {
+		in, value string
+	}{
+		{"Name=%zz", "%zz"},
+		{"Na%me=Homer", ""},
+	} {
+		err := formam.NewDecoder(nil).DecodeString(tt.in, &s)
+		var e *formam.Error
+		var escErr url.EscapeError
+		if !errors.As(err, &e) || !errors.As(err, &escErr) {
+			t.Errorf("%s: wrong error: %v", tt.in, err)
+			continue
+		}
+		if e.Value() != tt.value {
+			t.Errorf("%s: value is %q", tt.in, e.Value())
+		}
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+type readMark int
+
+func TestDecodeReaderStream(t *testing.T) {
+	in := "First=x&" + strings.Repeat("Tags=a&", 10000)
+	r := &countingReader{r: strings.NewReader(in)}
+	var s struct {
+		First readMark
+		Tags  []string
+	}
+	dec := formam.NewDecoder(nil).RegisterCustomType(func(vals []string) (interface{}, error) {
+		return readMark(r.n), nil
+	}, []interface{}{readMark(0)}, nil)
+	if err := dec.DecodeReader(r, &s); err != nil {
+		t.Fatal(err)
+	}
+	if int(s.First) >= len(in) {
+		t.Errorf("First is decoded after reading %d bytes of %d", s.First, len(in))
+	}
+	if len(s.Tags) != 10000 {
+		t.Errorf("len(Tags) is %d", len(s.Tags))
+	}
+}
+
+func TestDecodeReaderRepeatedKeys(t *testing.T) {
+	var s struct {
+		Name    string
+		Tags    []string
+		Arr     [3]int
+		Ptr     *[]string
+		Rows    []struct{ A, B string }
+		Price   FormMoney
+		Payment PaymentMethod
+	}
+	dec := formam.NewDecoder(nil).RegisterUnion((*PaymentMethod)(nil), "type", map[string]interface{}{
+		"card": &Card{},
+	})
+	err := dec.DecodeString("Tags=a&Name=x&Arr=1&Tags=b&Name=y&Arr=2&Ptr=a&"+
+		"Rows[].A=1&Rows[].B=2&Rows[].A=3&Price.amount=5&Payment.number=4242&"+
+		"Name=z&Ptr=b&Price.currency=EUR&Payment.type=card&Arr=3", &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "x" {
+		t.Errorf("Name is %q", s.Name)
+	}
+	if strings.Join(s.Tags, ",") != "a,b" {
+		t.Errorf("Tags is %q", s.Tags)
+	}
+	if s.Arr != [3]int{1, 2, 3} {
+		t.Errorf("Arr is %v", s.Arr)
+	}
+	if s.Ptr == nil || strings.Join(*s.Ptr, ",") != "a,b" {
+		t.Errorf("Ptr is %v", s.Ptr)
+	}
+	if len(s.Rows) != 2 || s.Rows[0].A != "1" || s.Rows[0].B != "2" || s.Rows[1].A != "3" {
+		t.Errorf("Rows is %+v", s.Rows)
+	}
+	if s.Price.Amount != 5 || s.Price.Currency != "EUR" {
+		t.Errorf("Price is %+v", s.Price)
+	}
+	if s.Payment == nil || s.Payment.Pay() != "card 4242" {
+		t.Errorf("Payment is %v", s.Payment)
+	}
+}
+
+func TestDecodeReaderArrayLength(t *testing.T) {
+	tests := []struct {
+		in        string
+		policy    formam.ArrayLengthPolicy
+		want      [3]int
+		wantError string
+	}{
+		{"Arr=1&Arr=2&Arr=3", formam.ArrayLengthExact, [3]int{1, 2, 3}, ""},
+		{"Arr=1&A=1&Arr=2", formam.ArrayLengthExact, [3]int{1, 2}, "2 values for an array of length 3"},
+		{"Arr=1&Arr=2&Arr=3&Arr=4", formam.ArrayLengthExact, [3]int{1, 2, 3}, "4 values for an array of length 3"},
+		{"Arr=1&Arr=2&Arr=3&Arr=4", formam.ArrayLengthTruncate, [3]int{1, 2, 3}, ""},
+		{"Arr=1&Arr=2&Arr=3&Arr=4", formam.ArrayLengthError, [3]int{1, 2, 3}, "4 values for an array of length 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var s struct {
+				A   int
+				Arr [3]int
+			}
+			dec := formam.NewDecoder(&formam.DecoderOptions{ArrayLength: tt.policy})
+			err := dec.DecodeString(tt.in, &s)
+			if !errorContains(err, tt.wantError) {
+				t.Fatalf("wrong error: %s", err)
+			}
+			if s.Arr != tt.want {
+				t.Errorf("Arr is %v", s.Arr)
+			}
+		})
+	}
+}
+
+func TestDecodeReaderFailedKey(t *testing.T) {
+	var s struct{ Arr []int }
+	dec := formam.NewDecoder(&formam.DecoderOptions{CollectErrors: true})
+	err := dec.DecodeString("Arr=x&Arr=y&Arr=1", &s)
+	if errs, ok := err.(formam.Errors); !ok || len(errs) != 1 || errs[0].Path() != "Arr" {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
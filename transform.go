@@ -0,0 +1,62 @@
+package formam
+
+import (
+	"strings"
+)
+
+// TransformFunc transforms a value before it is decoded.
+type TransformFunc func(string) string
+
+// transforms are the built-in transforms.
+var transforms = map[string]TransformFunc{
+	"trim":  strings.TrimSpace,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// RegisterTransform registers a function to transform the values of the
+// struct fields with the option name in their tag, before they are decoded.
+//
+// The built-in transforms are "trim" (strings.TrimSpace), "lower"
+// (strings.ToLower) and "upper" (strings.ToUpper); a registered transform
+// with the same name replaces them. Transforms run in the order of the tag,
+// for any type, including custom types and UnmarshalText; e.g. with:
+//
+//	Email string `formam:"email,trim,lower"`
+func (dec *Decoder) RegisterTransform(name string, fn TransformFunc) *Decoder {
+	if dec.transforms == nil {
+		dec.transforms = make(map[string]TransformFunc)
+	}
+	dec.transforms[name] = fn
+	return dec
+}
+
+// transform runs the transforms in the tag of the current struct field on
+// the values of the current path.
+func (dec *Decoder) transform() {
+	opts, ok := tagOptions(dec.structField.Tag, dec.opts.TagName)
+	if !ok {
+		return
+	}
+
+	var values []string
+	for opts != "" {
+		var opt string
+		opt, opts = nextTagOption(opts)
+		fn, ok := dec.transforms[opt]
+		if !ok {
+			if fn, ok = transforms[opt]; !ok {
+				continue
+			}
+		}
+		if values == nil {
+			// don't modify the values passed to Decode
+			values = make([]string, len(dec.currValues))
+			copy(values, dec.currValues)
+			dec.currValues = values
+		}
+		for i := range values {
+			values[i] = fn(values[i])
+		}
+	}
+}
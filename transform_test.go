@@ -0,0 +1,70 @@
+package formam_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+func TestTransform(t *testing.T) {
+	s := struct {
+		Email  string                  `formam:"email,trim,lower"`
+		Age    int                     `formam:",trim"`
+		Tags   []string                `formam:"tags,upper,trim"`
+		Text   StringWithUnmarshalText `formam:",nospace"`
+		Custom FieldString             `formam:",nospace,x"`
+		Name   string                  `formam:"name,trim,nospace"`
+		Raw    string
+	}{}
+
+	vals := url.Values{
+		"email":  []string{"  Homer@Example.COM "},
+		"Age":    []string{" 39\n"},
+		"tags[]": []string{" dad ", "safety inspector"},
+		"Text":   []string{"a b"},
+		"Custom": []string{"a b"},
+		"name":   []string{" Homer Jay "},
+		"Raw":    []string{" Raw "},
+	}
+
+	var custom []string
+	dec := formam.NewDecoder(nil).
+		RegisterTransform("nospace", func(s string) string {
+			return strings.Replace(s, " ", "", -1)
+		}).
+		RegisterCustomType(func(vals []string) (interface{}, error) {
+			custom = vals
+			return FieldString(vals[0]), nil
+		}, []interface{}{FieldString("")}, nil)
+
+	if err := dec.Decode(vals, &s); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Email != "homer@example.com" {
+		t.Errorf("Email is %q", s.Email)
+	}
+	if s.Age != 39 {
+		t.Errorf("Age is %d", s.Age)
+	}
+	if strings.Join(s.Tags, ",") != "DAD,SAFETY INSPECTOR" {
+		t.Errorf("Tags is %q", s.Tags)
+	}
+	if s.Text != "string by UnmarshalText" {
+		t.Errorf("Text is %q", s.Text)
+	}
+	if s.Custom != "ab" || len(custom) != 1 || custom[0] != "ab" {
+		t.Errorf("Custom is %q, and the custom type got %q", s.Custom, custom)
+	}
+	if s.Name != "HomerJay" {
+		t.Errorf("Name is %q", s.Name)
+	}
+	if s.Raw != " Raw " {
+		t.Errorf("Raw is %q", s.Raw)
+	}
+	if vals.Get("email") != "  Homer@Example.COM " {
+		t.Errorf("the values passed to Decode are modified: %q", vals.Get("email"))
+	}
+}
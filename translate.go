@@ -0,0 +1,142 @@
+package formam
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Translator returns the message of an error for the users of a form, from
+// its code, field, path, value and type; or "" to use the English message.
+type Translator func(err *Error) string
+
+// RegisterTranslator registers the translator for the language lang, used by
+// the Message method of the errors when the Language option or method
+// selects it.
+func (dec *Decoder) RegisterTranslator(lang string, fn Translator) *Decoder {
+	if dec.translators == nil {
+		dec.translators = make(map[string]Translator)
+	}
+	dec.translators[lang] = fn
+	return dec
+}
+
+// Language returns a copy of the decoder with the messages of its errors in
+// the language lang; e.g. to use the language of each request with a shared
+// decoder:
+//
+//	err := dec.Language(lang).Decode(r.Form, &dst)
+func (dec Decoder) Language(lang string) *Decoder {
+	opts := *dec.opts
+	opts.Language = lang
+	dec.opts = &opts
+	return &dec
+}
+
+// Catalog returns a translator with a message template for every error code.
+// The placeholders {field}, {path}, {value} and {type} in the templates are
+// replaced by the ones of the error; the codes without a template get the
+// English message. For example:
+//
+//	dec.RegisterTranslator("es", formam.Catalog(map[formam.ErrorCode]string{
+//		formam.ErrCodeConversion: "{value} no es un valor válido",
+//	}))
+func Catalog(messages map[ErrorCode]string) Translator {
+	return func(err *Error) string {
+		msg, ok := messages[err.code]
+		if !ok {
+			return ""
+		}
+		typ := ""
+		if err.typ != nil {
+			typ = err.typ.String()
+		}
+		return strings.NewReplacer(
+			"{field}", err.field,
+			"{path}", err.path,
+			"{value}", err.value,
+			"{type}", typ,
+		).Replace(msg)
+	}
+}
+
+// English returns the message of the error in English, such as
+// `"abc" is not a valid whole number`.
+func English(err *Error) string {
+	switch err.code {
+	case ErrCodeNotAPointer:
+		return "the destination is not a pointer"
+	case ErrCodeArrayIndex:
+		return fmt.Sprintf("%s has an invalid index", err.path)
+	case ErrCodeConversion:
+		return fmt.Sprintf("%q is not a valid %s", err.value, describeType(err.typ))
+	case ErrCodeUnknownType:
+		return fmt.Sprintf("%s can't be set", err.path)
+	case ErrCodeUnknownField:
+		return fmt.Sprintf("%s is not a known field", err.path)
+	case ErrCodeRange:
+		return fmt.Sprintf("%q is out of range", err.value)
+	case ErrCodeArraySize:
+		return fmt.Sprintf("%s has too many elements", err.path)
+	case ErrCodeSyntax:
+		return fmt.Sprintf("%s is not a valid key", err.path)
+	case ErrCodeFormSize:
+		return "the form is too long"
+	case ErrCodeValidation:
+		return err.err.Error()
+	case ErrCodeNotAllowed:
+		return fmt.Sprintf("%s is not allowed", err.path)
+	case ErrCodeArrayLength:
+		if err.typ != nil && err.typ.Kind() == reflect.Array {
+			return fmt.Sprintf("the values of %s don't fit its %d elements", err.path, err.typ.Len())
+		}
+		return fmt.Sprintf("%s has the wrong number of values", err.path)
+	case ErrCodePanic:
+		return fmt.Sprintf("%s couldn't be decoded", err.path)
+	}
+	return err.err.Error()
+}
+
+// describeType returns a description of typ for the English messages.
+func describeType(typ reflect.Type) string {
+	if typ == nil {
+		return "value"
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ {
+	case typeTime:
+		return "date"
+	case typeURL:
+		return "URL"
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "whole number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Complex64, reflect.Complex128:
+		return "complex number"
+	}
+	return "value"
+}
+
+// translate sets the translator of the Language option in err, if it's an
+// *Error or Errors.
+func (dec *Decoder) translate(err error) error {
+	tr := dec.translators[dec.opts.Language]
+	if tr == nil {
+		return err
+	}
+	switch err := err.(type) {
+	case *Error:
+		err.tr = tr
+	case Errors:
+		for _, e := range err {
+			e.tr = tr
+		}
+	}
+	return err
+}
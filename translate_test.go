@@ -0,0 +1,91 @@
+package formam_test
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monoculum/formam/v3"
+)
+
+func TestTranslator(t *testing.T) {
+	type form struct {
+		Age   int
+		Price float64
+		Date  time.Time
+		Name  string
+	}
+
+	dec := formam.NewDecoder(&formam.DecoderOptions{CollectErrors: true}).
+		RegisterTranslator("es", formam.Catalog(map[formam.ErrorCode]string{
+			formam.ErrCodeConversion:   "{path}: {value} no es un valor válido de tipo {type}",
+			formam.ErrCodeUnknownField: "{field} no existe",
+		})).
+		RegisterTranslator("xx", func(err *formam.Error) string {
+			return fmt.Sprintf("%s/%s/%s/%s/%v", err.Code(), err.Field(), err.Path(), err.Value(), err.Type())
+		})
+	vals := url.Values{
+		"Age":   []string{"abc"},
+		"Price": []string{"1,5"},
+		"Date":  []string{"yesterday"},
+		"Other": []string{"x"},
+		"Age2":  []string{"1"},
+	}
+
+	tests := []struct {
+		dec  *formam.Decoder
+		want []string
+	}{
+		{dec, []string{
+			`"abc" is not a valid whole number`,
+			`Age2 is not a known field`,
+			`"yesterday" is not a valid date`,
+			`Other is not a known field`,
+			`"1,5" is not a valid number`,
+		}},
+		{dec.Language("es"), []string{
+			`Age: abc no es un valor válido de tipo int`,
+			`Age2 no existe`,
+			`Date: yesterday no es un valor válido de tipo time.Time`,
+			`Other no existe`,
+			`Price: 1,5 no es un valor válido de tipo float64`,
+		}},
+		{dec.Language("xx"), []string{
+			`conversion/Age/Age/abc/int`,
+			`unknown_field/Age2/Age2/1/formam_test.form`,
+			`conversion/Date/Date/yesterday/time.Time`,
+			`unknown_field/Other/Other/x/formam_test.form`,
+			`conversion/Price/Price/1,5/float64`,
+		}},
+		{dec.Language("unknown"), []string{
+			`"abc" is not a valid whole number`,
+			`Age2 is not a known field`,
+			`"yesterday" is not a valid date`,
+			`Other is not a known field`,
+			`"1,5" is not a valid number`,
+		}},
+	}
+	for _, tt := range tests {
+		err := tt.dec.Decode(vals, &form{})
+		var errs formam.Errors
+		if !errors.As(err, &errs) {
+			t.Fatalf("not Errors: %v", err)
+		}
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, e.Message())
+		}
+		if strings.Join(msgs, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("\nout:  %q\nwant: %q", msgs, tt.want)
+		}
+	}
+
+	// the language of a copy doesn't change the decoder
+	err := dec.Decode(url.Values{"Age": []string{"abc"}}, &form{})
+	if msg := err.(formam.Errors)[0].Message(); msg != `"abc" is not a valid whole number` {
+		t.Errorf("message is %q", msg)
+	}
+}
@@ -0,0 +1,104 @@
+package formam
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// union holds the concrete types of an interface type.
+type union struct {
+	key   string                  // discriminator key
+	types map[string]reflect.Type // concrete types by the discriminator value
+}
+
+// RegisterUnion registers the concrete types for the interface type of
+// iface, which must be a pointer to an interface (e.g. (*PaymentMethod)(nil)).
+//
+// A nil field of this interface type gets the concrete type in types for the
+// value of the discriminator key next to it, and the other keys are decoded
+// in it. For example with:
+//
+//	dec.RegisterUnion((*PaymentMethod)(nil), "type", map[string]interface{}{
+//		"card": &Card{},
+//		"iban": &IBAN{},
+//	})
+//
+// Payment.type=iban&Payment.iban=X sets the Payment field to an *IBAN with
+// the IBAN field X. The concrete type doesn't need to have a field for the
+// discriminator.
+func (dec *Decoder) RegisterUnion(iface interface{}, discriminator string, types map[string]interface{}) *Decoder {
+	typ := reflect.TypeOf(iface)
+	if typ == nil || typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Interface {
+		panic("formam: RegisterUnion: iface is not a pointer to an interface")
+	}
+	typ = typ.Elem()
+
+	if dec.unions == nil {
+		dec.unions = make(map[reflect.Type]*union)
+	}
+	u := &union{key: discriminator, types: make(map[string]reflect.Type, len(types))}
+	for k, v := range types {
+		t := reflect.TypeOf(v)
+		if t == nil || !t.Implements(typ) {
+			panic("formam: RegisterUnion: the type for " + strconv.Quote(k) + " does not implement " + typ.String())
+		}
+		u.types[k] = t
+	}
+	dec.unions[typ] = u
+	return dec
+}
+
+// traverseUnion sets the concrete type registered in u in the current
+// interface if it is nil, using the value of the discriminator key.
+func (dec *Decoder) traverseUnion(u *union) error {
+	if !dec.curr.IsNil() {
+		return nil
+	}
+
+	value, ok := dec.lookup(append(dec.segs[:len(dec.segs):len(dec.segs)], PathSegment{Name: u.key}))
+	if !ok {
+		if dec.streaming {
+			return errPending
+		}
+		return dec.newError(ErrCodeUnknownType, "missing discriminator %q for %v", u.key, dec.curr.Type())
+	}
+	typ, ok := u.types[value]
+	if !ok {
+		return dec.newError(ErrCodeUnknownType, "unknown discriminator value %q for %v", value, dec.curr.Type())
+	}
+
+	if typ.Kind() == reflect.Ptr {
+		dec.curr.Set(reflect.New(typ.Elem()))
+	} else {
+		dec.curr.Set(reflect.New(typ).Elem())
+	}
+	return nil
+}
+
+// lookup finds the first value of the key with the path segs. The segments
+// are compared by their names, and an empty index in the key matches the
+// element with the same position as the value.
+func (dec *Decoder) lookup(segs []PathSegment) (string, bool) {
+	for _, k := range dec.keys {
+		ks, err := dec.opts.PathParser.ParsePath(k)
+		if err != nil || len(ks) != len(segs) {
+			continue
+		}
+		row, match := 0, true
+		for i := range ks {
+			if ks[i].Name == segs[i].Name {
+				continue
+			}
+			if ks[i].Kind != FieldSegment && ks[i].Name == "" && isIndex(segs[i].Name) {
+				row, _ = strconv.Atoi(segs[i].Name)
+				continue
+			}
+			match = false
+			break
+		}
+		if match && row < len(dec.values[k]) {
+			return dec.values[k][row], true
+		}
+	}
+	return "", false
+}
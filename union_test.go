@@ -0,0 +1,87 @@
+package formam_test
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+type PaymentMethod interface {
+	Pay() string
+}
+
+type Card struct {
+	Number string `formam:"number"`
+}
+
+func (c *Card) Pay() string { return "card " + c.Number }
+
+type IBAN struct {
+	Type string `formam:"type"`
+	IBAN string `formam:"iban"`
+}
+
+func (i IBAN) Pay() string { return i.Type + " " + i.IBAN }
+
+func TestRegisterUnion(t *testing.T) {
+	newDecoder := func() *formam.Decoder {
+		return formam.NewDecoder(&formam.DecoderOptions{BracketFields: true}).RegisterUnion((*PaymentMethod)(nil), "type", map[string]interface{}{
+			"card": &Card{},
+			"iban": IBAN{},
+		})
+	}
+
+	tests := []struct {
+		in        url.Values
+		want      string
+		wantError string
+	}{
+		{url.Values{"Payment.type": {"card"}, "Payment.number": {"4242"}}, "card 4242", ""},
+		{url.Values{"Payment[type]": {"iban"}, "Payment[iban]": {"ES12"}}, "iban ES12", ""},
+		{url.Values{"Payment.number": {"4242"}}, "<nil>", `missing discriminator "type"`},
+		{url.Values{"Payment.type": {"cash"}}, "<nil>", `unknown discriminator value "cash"`},
+		{url.Values{"Payment.type": {"card"}, "Payment.iban": {"ES12"}}, "card ", "unknown field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in.Encode(), func(t *testing.T) {
+			var s struct {
+				Payment PaymentMethod
+			}
+			err := newDecoder().Decode(tt.in, &s)
+			if !errorContains(err, tt.wantError) {
+				t.Fatalf("wrong error: %s", err)
+			}
+			out := "<nil>"
+			if s.Payment != nil {
+				out = s.Payment.Pay()
+			}
+			if out != tt.want {
+				t.Errorf("\nout:  %s\nwant: %s", out, tt.want)
+			}
+		})
+	}
+
+	t.Run("rows", func(t *testing.T) {
+		var s struct {
+			Payments []PaymentMethod
+		}
+		for _, in := range []string{
+			"Payments[0][type]=card&Payments[0][number]=1&Payments[1][type]=iban&Payments[1][iban]=ES12",
+			"Payments[][type]=card&Payments[][type]=iban&Payments[][number]=1",
+		} {
+			s.Payments = nil
+			err := newDecoder().DecodeString(in, &s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := fmt.Sprintf("%s, %s", s.Payments[0].Pay(), s.Payments[1].Pay())
+			if want := "card 1, iban "; !strings.HasPrefix(out, want) {
+				t.Errorf("\nout:  %s\nwant: %s", out, want)
+			}
+		}
+	})
+}
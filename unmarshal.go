@@ -0,0 +1,166 @@
+package formam
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+// FormUnmarshaler is implemented by types that decode themselves from all
+// the keys under their path, such as a Money type from price.amount and
+// price.currency.
+//
+// UnmarshalForm gets the keys relative to the path of the value, e.g.
+// "amount" and "currency", and the value of the path itself with the key "".
+// It's called once for every value, instead of decoding its fields, when all
+// the keys are decoded, and its error is returned as an *Error with the code
+// ErrCodeConversion.
+type FormUnmarshaler interface {
+	UnmarshalForm(vs url.Values) error
+}
+
+var typeFormUnmarshaler = reflect.TypeOf((*FormUnmarshaler)(nil)).Elem()
+
+// formUnmarshaler holds the path of a value that implements FormUnmarshaler.
+// UnmarshalForm is called when all the keys are decoded, because DecodeReader
+// can read the keys under the path of the value after others.
+type formUnmarshaler struct {
+	prefix []PathSegment // segments of the path of the value in the keys
+	steps  []valueStep   // steps to the value from the destination
+	names  []pathName    // names of the segments, for Allow and Deny
+	path   string        // key that found the value
+	field  string        // field of the value
+	value  string        // first value of the key
+}
+
+// unmarshalForm records the current value if it implements FormUnmarshaler,
+// to call UnmarshalForm with the keys that have the same first n segments as
+// the current key. It reports if the value implements it.
+func (dec *Decoder) unmarshalForm(n int) (bool, error) {
+	if !dec.curr.IsValid() || !implements(dec.curr.Type(), typeFormUnmarshaler) {
+		return false, nil
+	}
+	prefix := dec.keySegs[:n]
+	for _, seg := range prefix {
+		// the rows of an empty index are not a single value
+		if seg.Kind != FieldSegment && seg.Name == "" {
+			return false, nil
+		}
+	}
+
+	path := FormatPath(prefix)
+	if dec.unmarshaled[path] {
+		return true, nil
+	}
+	if dec.unmarshaled == nil {
+		dec.unmarshaled = make(map[string]bool)
+	}
+	dec.unmarshaled[path] = true
+
+	u := formUnmarshaler{
+		prefix: make([]PathSegment, n),
+		steps:  make([]valueStep, len(dec.steps)),
+		names:  make([]pathName, len(dec.names)),
+		path:   dec.path,
+		field:  dec.field,
+	}
+	copy(u.prefix, prefix)
+	copy(u.steps, dec.steps)
+	copy(u.names, dec.names)
+	if len(dec.currValues) > 0 {
+		u.value = dec.currValues[0]
+	}
+	dec.unmarshalers = append(dec.unmarshalers, u)
+	return true, nil
+}
+
+// unmarshalForms calls UnmarshalForm on the values recorded by unmarshalForm.
+// The errors are added to dec.errs with the CollectErrors option.
+func (dec *Decoder) unmarshalForms() error {
+	for _, u := range dec.unmarshalers {
+		if err := dec.context().Err(); err != nil {
+			return err
+		}
+		u := u
+		vs, err := dec.unmarshalValues(u)
+		if err == nil {
+			err = dec.lookupValue(dec.main, u.steps, func(v reflect.Value) error {
+				var m FormUnmarshaler
+				if v.CanAddr() {
+					m, _ = v.Addr().Interface().(FormUnmarshaler)
+				}
+				if m == nil {
+					if m, _ = v.Interface().(FormUnmarshaler); m == nil {
+						return nil
+					}
+				}
+				if err := m.UnmarshalForm(vs); err != nil {
+					return &Error{code: ErrCodeConversion, field: u.field, path: u.path, value: u.value, typ: v.Type(), err: fmt.Errorf("could not decode field: %w", err)}
+				}
+				return nil
+			})
+		}
+		if err != nil {
+			if err := dec.collect(&dec.errs, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unmarshalValues returns the values of the keys under the path of u,
+// relative to it.
+func (dec *Decoder) unmarshalValues(u formUnmarshaler) (url.Values, error) {
+	n := len(u.prefix)
+	dec.path, dec.field, dec.names = u.path, u.field, u.names
+	vs := make(url.Values)
+	for _, k := range dec.keys {
+		segs, err := dec.opts.PathParser.ParsePath(k)
+		if err != nil || len(segs) < n || !sameNames(segs[:n], u.prefix) {
+			continue
+		}
+		if err := dec.allowedKey(k, segs[n:]); err != nil {
+			if err == errSkip {
+				continue
+			}
+			return nil, err
+		}
+		vs[FormatPath(segs[n:])] = dec.values[k]
+	}
+	return vs, nil
+}
+
+// allowedKey checks if the key k, with the segments segs after the current
+// path, is allowed by Allow and Deny.
+func (dec *Decoder) allowedKey(k string, segs []PathSegment) error {
+	if dec.allow == nil && dec.deny == nil {
+		return nil
+	}
+	names := make([]pathName, 0, len(segs))
+	for _, seg := range segs {
+		if seg.Kind == FieldSegment || seg.Name != "" {
+			names = append(names, pathName{name: seg.Name})
+		}
+	}
+
+	// the errors are for the key
+	path, field, values := dec.path, dec.field, dec.currValues
+	dec.path, dec.currValues = k, dec.values[k]
+	if len(names) > 0 {
+		dec.field = names[len(names)-1].name
+	}
+	err := dec.allowed(true, names...)
+	dec.path, dec.field, dec.currValues = path, field, values
+	return err
+}
+
+// sameNames reports if the segments have the same names.
+func sameNames(a, b []PathSegment) bool {
+	for i := range a {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
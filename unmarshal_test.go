@@ -0,0 +1,119 @@
+package formam_test
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+type FormMoney struct {
+	Amount   int64
+	Currency string
+	keys     []string
+}
+
+func (m *FormMoney) UnmarshalForm(vs url.Values) error {
+	for k := range vs {
+		m.keys = append(m.keys, k)
+	}
+	sort.Strings(m.keys)
+	if v := vs.Get(""); v != "" {
+		// a single value, such as "12 EUR"
+		parts := strings.Fields(v)
+		vs = url.Values{"amount": {parts[0]}, "currency": parts[1:]}
+	}
+	amount, err := strconv.ParseInt(vs.Get("amount"), 10, 64)
+	if err != nil {
+		return err
+	}
+	if vs.Get("currency") == "" {
+		return errors.New("currency is required")
+	}
+	m.Amount, m.Currency = amount, vs.Get("currency")
+	return nil
+}
+
+func TestFormUnmarshaler(t *testing.T) {
+	s := struct {
+		Name   string
+		Price  FormMoney
+		Tip    *FormMoney
+		Lines  []FormMoney
+		Totals map[string]FormMoney
+		Single FormMoney
+	}{}
+	err := formam.NewDecoder(nil).Decode(url.Values{
+		"Name":               {"Homer"},
+		"Price.amount":       {"10"},
+		"Price.currency":     {"EUR"},
+		"Tip.amount":         {"1"},
+		"Tip.currency":       {"USD"},
+		"Lines[0].amount":    {"2"},
+		"Lines[0].currency":  {"EUR"},
+		"Lines[1].amount":    {"3"},
+		"Lines[1].currency":  {"GBP"},
+		"Lines[1].x[0]":      {"y"},
+		"Totals[a].amount":   {"4"},
+		"Totals[a].currency": {"JPY"},
+		"Single":             {"12 CHF"},
+	}, &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		have FormMoney
+		want string
+	}{
+		{s.Price, "10 EUR amount,currency"},
+		{*s.Tip, "1 USD amount,currency"},
+		{s.Lines[0], "2 EUR amount,currency"},
+		{s.Lines[1], "3 GBP amount,currency,x[0]"},
+		{s.Totals["a"], "4 JPY amount,currency"},
+		{s.Single, "12 CHF "},
+	} {
+		have := strconv.FormatInt(tt.have.Amount, 10) + " " + tt.have.Currency + " " + strings.Join(tt.have.keys, ",")
+		if have != tt.want {
+			t.Errorf("have %q, want %q", have, tt.want)
+		}
+	}
+
+	err = formam.NewDecoder(nil).Decode(url.Values{"Price.amount": {"10"}}, &s)
+	var e *formam.Error
+	if !errors.As(err, &e) || e.Code() != formam.ErrCodeConversion || e.Path() != "Price.amount" {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	// the keys that are not allowed are not passed
+	vals := url.Values{"Price.amount": {"10"}, "Price.currency": {"EUR"}, "Price.secret": {"x"}}
+	for _, dec := range []*formam.Decoder{
+		formam.NewDecoder(nil).Deny("Price.secret"),
+		formam.NewDecoder(nil).Allow("Name", "Price.amount", "Price.currency"),
+	} {
+		s.Price = FormMoney{}
+		if err := dec.Decode(vals, &s); err != nil {
+			t.Fatal(err)
+		}
+		if keys := strings.Join(s.Price.keys, ","); keys != "amount,currency" {
+			t.Errorf("keys are %q", keys)
+		}
+	}
+	err = formam.NewDecoder(&formam.DecoderOptions{RejectDisallowed: true}).Deny("Price.secret").Decode(vals, &s)
+	if !errors.As(err, &e) || e.Code() != formam.ErrCodeNotAllowed || e.Path() != "Price.secret" {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	// the destination gets all the keys
+	var m FormMoney
+	if err := formam.Decode(url.Values{"amount": {"5"}, "currency": {"EUR"}}, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Amount != 5 || m.Currency != "EUR" {
+		t.Errorf("%+v", m)
+	}
+}
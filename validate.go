@@ -0,0 +1,201 @@
+package formam
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+// Validator is implemented by types that validate themselves after being
+// decoded.
+//
+// Decode calls Validate on the destination and on every struct, slice
+// element and map value with a key in the form, from the innermost to the
+// destination, and returns the first error as an *Error with the code
+// ErrCodeValidation and the path of the value.
+type Validator interface {
+	Validate() error
+}
+
+// ContextValidator is like Validator, with the context passed to
+// DecodeContext, or context.Background() for Decode. It has preference over
+// Validate.
+type ContextValidator interface {
+	ValidateContext(ctx context.Context) error
+}
+
+var (
+	typeValidator        = reflect.TypeOf((*Validator)(nil)).Elem()
+	typeContextValidator = reflect.TypeOf((*ContextValidator)(nil)).Elem()
+)
+
+// validation holds the path of a decoded value to validate. The value is
+// looked up when the form is decoded, because it can be moved, as the
+// elements of a slice that grows, or copied, as the values of a map.
+type validation struct {
+	steps []valueStep   // steps to the value from the destination
+	segs  []PathSegment // path of the value
+}
+
+// valueStep is a step in the path to a value: a struct field, an element of
+// a slice or array, or a value of a map.
+type valueStep struct {
+	field string   // name of the struct field
+	index int      // index of the element, or -1
+	m     *pathMap // value of the map
+}
+
+// touch records the current value to validate it when the form is decoded.
+func (dec *Decoder) touch() {
+	if !implements(dec.curr.Type(), typeValidator, typeContextValidator) {
+		return
+	}
+
+	path := FormatPath(dec.segs)
+	if _, ok := dec.touched[path]; ok {
+		return
+	}
+	if dec.touched == nil {
+		dec.touched = make(map[string]int)
+	}
+	dec.touched[path] = len(dec.validations)
+	steps := make([]valueStep, len(dec.steps))
+	copy(steps, dec.steps)
+	segs := make([]PathSegment, len(dec.segs))
+	copy(segs, dec.segs)
+	dec.validations = append(dec.validations, validation{steps, segs})
+}
+
+// validate calls the Validate method of the values decoded, from the
+// innermost to the destination. The errors are added to errs with the
+// CollectErrors option.
+func (dec *Decoder) validate(errs *Errors) error {
+	dec.curr = dec.main
+	dec.segs = dec.segs[:0]
+	dec.steps = dec.steps[:0]
+	dec.touch()
+
+	sort.SliceStable(dec.validations, func(i, j int) bool {
+		return len(dec.validations[i].segs) > len(dec.validations[j].segs)
+	})
+	for _, v := range dec.validations {
+		if err := dec.context().Err(); err != nil {
+			return err
+		}
+		v := v
+		err := dec.lookupValue(dec.main, v.steps, func(value reflect.Value) error {
+			err := callValidate(dec.context(), value)
+			if err == nil {
+				return nil
+			}
+			field := ""
+			if len(v.segs) > 0 {
+				field = v.segs[len(v.segs)-1].Name
+			}
+			return &Error{code: ErrCodeValidation, field: field, path: FormatPath(v.segs), typ: value.Type(), err: err}
+		})
+		if err != nil {
+			if err := dec.collect(errs, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookupValue calls fn with the value at the end of the steps from v. The
+// copies of the values of maps and interfaces are put back in them after fn
+// is called. The values that are not found, such as the ones of a map key
+// that couldn't be decoded or nil pointers, are skipped.
+func (dec *Decoder) lookupValue(v reflect.Value, steps []valueStep, fn func(reflect.Value) error) error {
+	for {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+			continue
+		case reflect.Interface:
+			if v.IsNil() {
+				return nil
+			}
+			elem := v.Elem()
+			if elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Map {
+				v = elem
+				continue
+			}
+			c := reflect.New(elem.Type()).Elem()
+			c.Set(elem)
+			err := dec.lookupValue(c, steps, fn)
+			v.Set(c)
+			return err
+		}
+		break
+	}
+	if len(steps) == 0 {
+		return fn(v)
+	}
+
+	step := steps[0]
+	switch v.Kind() {
+	case reflect.Map:
+		if step.m == nil || !step.m.mapKey.IsValid() {
+			return nil
+		}
+		elem := v.MapIndex(step.m.mapKey)
+		if !elem.IsValid() {
+			return nil
+		}
+		c := reflect.New(elem.Type()).Elem()
+		c.Set(elem)
+		err := dec.lookupValue(c, steps[1:], fn)
+		v.SetMapIndex(step.m.mapKey, c)
+		return err
+	case reflect.Slice, reflect.Array:
+		if step.index < 0 {
+			break
+		}
+		if step.index >= v.Len() {
+			return nil
+		}
+		v = v.Index(step.index)
+	case reflect.Struct:
+		if step.field == "" {
+			break
+		}
+		dec.curr, dec.field = v, step.field
+		if err := dec.lookupStructField(); err != nil {
+			return nil
+		}
+		v = dec.curr
+	}
+	return dec.lookupValue(v, steps[1:], fn)
+}
+
+// callValidate calls the ValidateContext or Validate method of v or its
+// address, if it has one.
+func callValidate(ctx context.Context, v reflect.Value) error {
+	if v.CanAddr() {
+		if ok, err := validateValue(ctx, v.Addr()); ok {
+			return err
+		}
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	_, err := validateValue(ctx, v)
+	return err
+}
+
+// validateValue calls the ValidateContext or Validate method of v, and
+// reports if it has one.
+func validateValue(ctx context.Context, v reflect.Value) (bool, error) {
+	switch m := v.Interface().(type) {
+	case ContextValidator:
+		return true, m.ValidateContext(ctx)
+	case Validator:
+		return true, m.Validate()
+	}
+	return false, nil
+}
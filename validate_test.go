@@ -0,0 +1,140 @@
+package formam_test
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/monoculum/formam/v3"
+)
+
+type ValidatedPerson struct {
+	Name string
+	Age  int
+}
+
+func (p *ValidatedPerson) Validate() error {
+	if p.Age < 0 {
+		return errors.New("age is negative")
+	}
+	return nil
+}
+
+type ValidatedPet string
+
+func (p ValidatedPet) Validate() error {
+	if p == "" {
+		return errors.New("pet has no name")
+	}
+	return nil
+}
+
+type ValidatedForm struct {
+	People []ValidatedPerson
+	Boss   *ValidatedPerson
+	Pets   map[string]ValidatedPet
+	Tags   []ValidatedPet
+}
+
+func (f ValidatedForm) Validate() error {
+	if len(f.People) == 0 {
+		return errors.New("no people")
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		in        url.Values
+		wantCode  formam.ErrorCode
+		wantPath  string
+		wantError string
+	}{
+		{url.Values{"People[0].Age": {"1"}, "Pets[cat]": {"Garfield"}, "Tags": {"a", "b"}}, 0, "", ""},
+		{url.Values{"Pets[cat]": {"Garfield"}}, formam.ErrCodeValidation, "", "no people"},
+		{url.Values{"People[0].Age": {"1"}, "People[1].Age": {"-1"}, "People[2].Age": {"1"}}, formam.ErrCodeValidation, "People[1]", "age is negative"},
+		{url.Values{"People[1].Name": {"Homer"}, "Boss.Age": {"-1"}}, formam.ErrCodeValidation, "Boss", "age is negative"},
+		{url.Values{"People[1].Age": {"1"}, "Pets[cat]": {""}}, formam.ErrCodeValidation, "Pets[cat]", "pet has no name"},
+		{url.Values{"People[1].Age": {"1"}, "Tags": {"a", ""}}, formam.ErrCodeValidation, "Tags[1]", "pet has no name"},
+		{url.Values{"People[1].Age": {"x"}, "Boss.Age": {"-1"}}, formam.ErrCodeConversion, "People[1].Age", "could not parse number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in.Encode(), func(t *testing.T) {
+			var f ValidatedForm
+			err := formam.NewDecoder(nil).Decode(tt.in, &f)
+			if !errorContains(err, tt.wantError) {
+				t.Fatalf("wrong error: %s", err)
+			}
+			if err == nil {
+				return
+			}
+			fErr := err.(*formam.Error)
+			if fErr.Code() != tt.wantCode {
+				t.Errorf("error code is %d", fErr.Code())
+			}
+			if fErr.Path() != tt.wantPath {
+				t.Errorf("error path is %q", fErr.Path())
+			}
+		})
+	}
+
+	t.Run("innermost first", func(t *testing.T) {
+		var f ValidatedForm
+		err := formam.NewDecoder(nil).Decode(url.Values{"Tags": {""}}, &f)
+		if !errorContains(err, "pet has no name") || !strings.Contains(err.Error(), "path=Tags[0]") {
+			t.Fatalf("wrong error: %s", err)
+		}
+	})
+}
+
+type ValidatedSub struct {
+	A, B      string
+	Validated bool
+}
+
+func (s *ValidatedSub) Validate() error {
+	s.Validated = true
+	return nil
+}
+
+type ValidatedItem struct {
+	A   int
+	Sub ValidatedSub
+}
+
+func TestValidatorMovedValues(t *testing.T) {
+	var f struct {
+		Items []ValidatedItem
+		Subs  map[string]ValidatedSub
+		Ptr   interface{}
+		Value interface{}
+	}
+	f.Ptr, f.Value = &ValidatedItem{}, ValidatedItem{}
+	err := formam.NewDecoder(nil).Decode(url.Values{
+		// the slice grows after Items[0].Sub is decoded
+		"Items[0].Sub.B": {"x"},
+		"Items[1].A":     {"2"},
+		"Items[2].A":     {"3"},
+		"Subs[a].A":      {"1"},
+		"Subs[a].B":      {"2"},
+		"Ptr.Sub.A":      {"1"},
+		"Value.Sub.A":    {"1"},
+	}, &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Items) != 3 || !f.Items[0].Sub.Validated || f.Items[0].Sub.B != "x" {
+		t.Errorf("Items: %+v", f.Items)
+	}
+	if s := f.Subs["a"]; !s.Validated || s.A != "1" || s.B != "2" {
+		t.Errorf("Subs: %+v", f.Subs)
+	}
+	if s := f.Ptr.(*ValidatedItem).Sub; !s.Validated || s.A != "1" {
+		t.Errorf("Ptr: %+v", s)
+	}
+	if s := f.Value.(ValidatedItem).Sub; !s.Validated || s.A != "1" {
+		t.Errorf("Value: %+v", s)
+	}
+}